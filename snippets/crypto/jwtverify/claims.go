@@ -0,0 +1,208 @@
+package jwtverify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Claim validation errors. Validate wraps them with details, so test with
+// errors.Is.
+var (
+	ErrTokenExpired      = errors.New("jwt: token expired")
+	ErrTokenNotYetValid  = errors.New("jwt: token not valid yet")
+	ErrTokenIssuedFuture = errors.New("jwt: token issued in the future")
+	ErrTokenTooOld       = errors.New("jwt: token too old")
+	ErrIssuerMismatch    = errors.New("jwt: issuer mismatch")
+	ErrAudienceMismatch  = errors.New("jwt: audience mismatch")
+	ErrSubjectMismatch   = errors.New("jwt: subject mismatch")
+	ErrMissingClaim      = errors.New("jwt: missing required claim")
+	ErrInvalidClaim      = errors.New("jwt: invalid claim")
+)
+
+// Validator checks the registered claims of a verified payload (RFC 7519
+// section 4.1). The zero value only checks exp, nbf and iat when present and
+// well-formed.
+type Validator struct {
+	// Issuers, if non-empty, lists the accepted "iss" values.
+	Issuers []string
+	// Audiences, if non-empty, requires "aud" (string or array) to contain at
+	// least one of these values.
+	Audiences []string
+	// Subject, if set, is the required "sub" value.
+	Subject string
+	// Required lists claims that must be present and not null, e.g. "exp",
+	// "jti".
+	Required []string
+	// MaxAge, if positive, rejects tokens whose "iat" is older than this.
+	// It implies "iat" is required.
+	MaxAge time.Duration
+	// Leeway is the allowed clock skew for exp, nbf, iat and MaxAge.
+	Leeway time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Validate checks claims (as returned by Verify) and returns the first
+// failure, wrapping one of the Err* sentinels.
+func (v *Validator) Validate(claims map[string]any) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	for _, name := range v.Required {
+		if c, ok := claims[name]; !ok || c == nil {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, name)
+		}
+	}
+
+	if exp, ok, err := numericDateClaim(claims, "exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(v.Leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrTokenExpired, exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok, err := numericDateClaim(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(v.Leeway).Before(nbf) {
+		return fmt.Errorf("%w: valid from %s", ErrTokenNotYetValid, nbf.UTC().Format(time.RFC3339))
+	}
+	iat, hasIat, err := numericDateClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if hasIat && now.Add(v.Leeway).Before(iat) {
+		return fmt.Errorf("%w: issued at %s", ErrTokenIssuedFuture, iat.UTC().Format(time.RFC3339))
+	}
+	if v.MaxAge > 0 {
+		if !hasIat {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, "iat")
+		}
+		if age := now.Sub(iat); age > v.MaxAge+v.Leeway {
+			return fmt.Errorf("%w: issued %s ago", ErrTokenTooOld, age.Round(time.Second))
+		}
+	}
+
+	if len(v.Issuers) > 0 {
+		iss, ok, err := stringClaim(claims, "iss")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, "iss")
+		}
+		if !slices.Contains(v.Issuers, iss) {
+			return fmt.Errorf("%w: %q", ErrIssuerMismatch, iss)
+		}
+	}
+	if len(v.Audiences) > 0 {
+		aud, ok, err := audienceClaim(claims)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, "aud")
+		}
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(v.Audiences, a) }) {
+			return fmt.Errorf("%w: %q", ErrAudienceMismatch, aud)
+		}
+	}
+	if v.Subject != "" {
+		sub, ok, err := stringClaim(claims, "sub")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, "sub")
+		}
+		if sub != v.Subject {
+			return fmt.Errorf("%w: %q", ErrSubjectMismatch, sub)
+		}
+	}
+	return nil
+}
+
+// maxNumericDate bounds NumericDate values to integers a float64 represents
+// exactly (about 285 million years), far inside the time.Time range.
+const maxNumericDate = 1 << 53
+
+// numericDateClaim reads a NumericDate (seconds since the epoch, possibly
+// fractional) as float64, json.Number or an integer type. null is invalid.
+func numericDateClaim(claims map[string]any, name string) (time.Time, bool, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	var secs float64
+	switch n := raw.(type) {
+	case float64:
+		secs = n
+	case json.Number:
+		f, err := n.Float64()
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %q is not a number", ErrInvalidClaim, name)
+		}
+		secs = f
+	case int64:
+		secs = float64(n)
+	case int:
+		secs = float64(n)
+	default:
+		return time.Time{}, false, fmt.Errorf("%w: %q is not a number", ErrInvalidClaim, name)
+	}
+	t, ok := secondsToTime(secs)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %q is out of range", ErrInvalidClaim, name)
+	}
+	return t, true, nil
+}
+
+// secondsToTime converts a NumericDate, rejecting NaN, infinities and values
+// beyond ±maxNumericDate.
+func secondsToTime(secs float64) (time.Time, bool) {
+	if math.IsNaN(secs) || math.Abs(secs) > maxNumericDate {
+		return time.Time{}, false
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)), true
+}
+
+func stringClaim(claims map[string]any, name string) (string, bool, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return "", false, nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return "", false, fmt.Errorf("%w: %q is not a string", ErrInvalidClaim, name)
+	}
+	return s, true, nil
+}
+
+// audienceClaim reads "aud", which may be a single string or an array of
+// strings.
+func audienceClaim(claims map[string]any) ([]string, bool, error) {
+	switch a := claims["aud"].(type) {
+	case nil:
+		return nil, false, nil
+	case string:
+		return []string{a}, true, nil
+	case []string:
+		return a, true, nil
+	case []any:
+		out := make([]string, 0, len(a))
+		for _, v := range a {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("%w: %q contains a non-string", ErrInvalidClaim, "aud")
+			}
+			out = append(out, s)
+		}
+		return out, true, nil
+	default:
+		return nil, false, fmt.Errorf("%w: %q is not a string or array", ErrInvalidClaim, "aud")
+	}
+}
@@ -0,0 +1,107 @@
+package jwtverify
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidator_TimeClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v := &Validator{Leeway: 30 * time.Second, MaxAge: time.Hour, Now: func() time.Time { return now }}
+	ts := func(d time.Duration) float64 { return float64(now.Add(d).Unix()) }
+
+	cases := []struct {
+		name   string
+		claims map[string]any
+		want   error
+	}{
+		{"valid", map[string]any{"iat": ts(-time.Minute), "exp": ts(time.Minute)}, nil},
+		{"expired within leeway", map[string]any{"iat": ts(-time.Minute), "exp": ts(-10 * time.Second)}, nil},
+		{"expired", map[string]any{"iat": ts(-time.Minute), "exp": ts(-time.Minute)}, ErrTokenExpired},
+		{"fractional exp", map[string]any{"iat": ts(-time.Minute), "exp": float64(now.Unix()-30) - 0.5}, ErrTokenExpired},
+		{"not yet valid", map[string]any{"iat": ts(-time.Minute), "nbf": ts(time.Minute)}, ErrTokenNotYetValid},
+		{"issued in future", map[string]any{"iat": ts(time.Minute)}, ErrTokenIssuedFuture},
+		{"too old", map[string]any{"iat": ts(-2 * time.Hour)}, ErrTokenTooOld},
+		{"max age needs iat", map[string]any{}, ErrMissingClaim},
+		{"json.Number", map[string]any{"iat": json.Number("1699999990"), "exp": json.Number("1699999000")}, ErrTokenExpired},
+		{"string exp", map[string]any{"iat": ts(0), "exp": "tomorrow"}, ErrInvalidClaim},
+		{"huge nbf", map[string]any{"iat": ts(0), "nbf": 1e300}, ErrInvalidClaim},
+		{"huge negative exp", map[string]any{"iat": ts(0), "exp": -1e300}, ErrInvalidClaim},
+		{"huge json.Number exp", map[string]any{"iat": ts(0), "exp": json.Number("1e300")}, ErrInvalidClaim},
+		{"null exp", map[string]any{"iat": ts(0), "exp": nil}, ErrInvalidClaim},
+		{"null iat", map[string]any{"iat": nil}, ErrInvalidClaim},
+	}
+	for _, tc := range cases {
+		err := v.Validate(tc.claims)
+		if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	required := &Validator{Required: []string{"exp"}}
+	if err := required.Validate(map[string]any{"exp": nil}); !errors.Is(err, ErrMissingClaim) {
+		t.Errorf("null required claim: %v", err)
+	}
+}
+
+func TestValidator_IssuerAudienceSubject(t *testing.T) {
+	v := &Validator{
+		Issuers:   []string{"https://idp.example.com"},
+		Audiences: []string{"api", "admin"},
+		Subject:   "user-1",
+		Required:  []string{"jti"},
+	}
+	base := func() map[string]any {
+		return map[string]any{"iss": "https://idp.example.com", "aud": "api", "sub": "user-1", "jti": "x"}
+	}
+
+	if err := v.Validate(base()); err != nil {
+		t.Fatalf("valid: %v", err)
+	}
+	c := base()
+	c["aud"] = []any{"other", "admin"}
+	if err := v.Validate(c); err != nil {
+		t.Fatalf("aud array: %v", err)
+	}
+
+	for name, mut := range map[string]struct {
+		f    func(map[string]any)
+		want error
+	}{
+		"issuer":      {func(c map[string]any) { c["iss"] = "https://evil.example.com" }, ErrIssuerMismatch},
+		"aud string":  {func(c map[string]any) { c["aud"] = "other" }, ErrAudienceMismatch},
+		"aud array":   {func(c map[string]any) { c["aud"] = []any{"x", "y"} }, ErrAudienceMismatch},
+		"aud missing": {func(c map[string]any) { delete(c, "aud") }, ErrMissingClaim},
+		"aud number":  {func(c map[string]any) { c["aud"] = 1.0 }, ErrInvalidClaim},
+		"subject":     {func(c map[string]any) { c["sub"] = "user-2" }, ErrSubjectMismatch},
+		"required":    {func(c map[string]any) { delete(c, "jti") }, ErrMissingClaim},
+	} {
+		c := base()
+		mut.f(c)
+		if err := v.Validate(c); !errors.Is(err, mut.want) {
+			t.Errorf("%s: got %v, want %v", name, err, mut.want)
+		}
+	}
+}
+
+func TestValidator_AfterVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := b64url(mustJSON(t, map[string]any{"alg": "EdDSA"})) + "." +
+		b64url(mustJSON(t, map[string]any{"aud": []string{"api"}, "exp": time.Now().Add(-time.Hour).Unix()}))
+	tok := input + "." + b64url(ed25519.Sign(priv, []byte(input)))
+
+	_, payload, err := Verify(tok, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&Validator{Audiences: []string{"api"}}).Validate(payload)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got %v, want ErrTokenExpired", err)
+	}
+}
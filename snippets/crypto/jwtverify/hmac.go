@@ -0,0 +1,50 @@
+package jwtverify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// hmacSecret returns key as an HMAC secret for hash h.
+//
+// Only []byte keys are accepted, so a *rsa.PublicKey can never be used as an
+// HS* secret. Because the classic algorithm-confusion attack instead feeds
+// the server's public key *bytes* (PEM or DER) in as the secret, secrets that
+// parse as a public key or certificate are rejected too. RFC 7518 section
+// 3.2 requires secrets at least as long as the hash output.
+func hmacSecret(key any, h crypto.Hash) ([]byte, error) {
+	secret, ok := key.([]byte)
+	if !ok {
+		return nil, errors.New("jwt: HS* requires a []byte secret")
+	}
+	if len(secret) < h.Size() {
+		return nil, fmt.Errorf("jwt: HMAC secret must be at least %d bytes", h.Size())
+	}
+	if looksLikePublicKey(secret) {
+		return nil, errors.New("jwt: HMAC secret looks like a public key")
+	}
+	return secret, nil
+}
+
+func looksLikePublicKey(b []byte) bool {
+	if bytes.Contains(b, []byte("-----BEGIN ")) {
+		return true
+	}
+	trimmed := bytes.TrimSpace(b)
+	if bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(trimmed, []byte(`"kty"`)) {
+		return true // a JWK
+	}
+	if _, err := x509.ParsePKIXPublicKey(b); err == nil {
+		return true
+	}
+	if _, err := x509.ParsePKCS1PublicKey(b); err == nil {
+		return true
+	}
+	if _, err := x509.ParseCertificate(b); err == nil {
+		return true
+	}
+	return false
+}
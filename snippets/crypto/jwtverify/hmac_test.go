@@ -0,0 +1,64 @@
+package jwtverify
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func hmacToken(t *testing.T, alg string, h crypto.Hash, secret []byte) string {
+	t.Helper()
+	input := b64url(mustJSON(t, map[string]any{"alg": alg})) + "." + b64url(mustJSON(t, map[string]any{"sub": "svc"}))
+	mac := hmac.New(h.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + b64url(mac.Sum(nil))
+}
+
+func TestVerify_HMAC(t *testing.T) {
+	secret := make([]byte, 64)
+	rand.Read(secret)
+	for alg, h := range map[string]crypto.Hash{"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512} {
+		tok := hmacToken(t, alg, h, secret)
+		if _, p, err := Verify(tok, secret); err != nil || p["sub"] != "svc" {
+			t.Fatalf("%s: payload %v, err %v", alg, p, err)
+		}
+		other := append([]byte{}, secret...)
+		other[0] ^= 1
+		if _, _, err := Verify(tok, other); err == nil {
+			t.Fatalf("%s: wrong secret accepted", alg)
+		}
+	}
+
+	short := make([]byte, 16)
+	if _, _, err := Verify(hmacToken(t, "HS256", crypto.SHA256, short), short); err == nil {
+		t.Fatal("short secret accepted")
+	}
+}
+
+func TestVerify_HMACAlgConfusion(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	// An attacker signs HS256 with the server's public key as the secret.
+	for name, secret := range map[string][]byte{"pem": pemBytes, "der": der} {
+		tok := hmacToken(t, "HS256", crypto.SHA256, secret)
+		if _, _, err := Verify(tok, secret); err == nil {
+			t.Fatalf("%s public key accepted as HMAC secret", name)
+		}
+	}
+	tok := hmacToken(t, "HS256", crypto.SHA256, pemBytes)
+	if _, _, err := Verify(tok, &priv.PublicKey); err == nil {
+		t.Fatal("*rsa.PublicKey accepted for HS256")
+	}
+}
@@ -0,0 +1,333 @@
+package jwtverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"maps"
+	"strings"
+)
+
+// Encrypt produces a compact JWE (RFC 7516) of plaintext.
+//
+// header must contain "alg"; "enc" defaults to A256GCM. header is copied,
+// not modified. Supported algorithms and the key they take:
+//   - RSA-OAEP, RSA-OAEP-256: *rsa.PublicKey
+//   - ECDH-ES, ECDH-ES+A128KW/A192KW/A256KW: *ecdsa.PublicKey (P-256/384/521)
+//   - A128KW, A192KW, A256KW: []byte of 16/24/32 bytes
+//   - dir: []byte of the content encryption key size
+//
+// Content encryption: A128GCM, A192GCM, A256GCM, A128CBC-HS256,
+// A192CBC-HS384, A256CBC-HS512. To encrypt a signed JWT (nested JWT), pass
+// the JWS as plaintext and set "cty" to "JWT".
+func Encrypt(header map[string]any, plaintext []byte, key any) (string, error) {
+	hdr := maps.Clone(header)
+	if hdr == nil {
+		hdr = map[string]any{}
+	}
+	alg, _ := hdr["alg"].(string)
+	if alg == "" {
+		return "", errors.New("jwt: missing alg")
+	}
+	enc, _ := hdr["enc"].(string)
+	if enc == "" {
+		enc = "A256GCM"
+		hdr["enc"] = enc
+	}
+	cc, err := contentCipherFor(enc)
+	if err != nil {
+		return "", err
+	}
+	cek, encryptedKey, err := wrapCEK(alg, enc, cc.keySize, key, hdr)
+	if err != nil {
+		return "", err
+	}
+
+	rawHdr, err := json.Marshal(hdr)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode header: %w", err)
+	}
+	protected := b64urlEncode(rawHdr)
+	iv := make([]byte, cc.ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	ciphertext, tag, err := cc.seal(cek, iv, plaintext, []byte(protected))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		protected, b64urlEncode(encryptedKey), b64urlEncode(iv), b64urlEncode(ciphertext), b64urlEncode(tag),
+	}, "."), nil
+}
+
+// Decrypt decrypts a compact JWE (five segments) and returns its protected
+// header and the plaintext. key is the private counterpart of the key
+// Encrypt takes: *rsa.PrivateKey, *ecdsa.PrivateKey or a []byte secret.
+//
+// Compressed ("zip") content is rejected, as is any "crit" extension, since
+// none are implemented for JWE (see Verifier.Decrypt). Sizes are bounded by
+// the default Limits.
+func Decrypt(token string, key any) (header map[string]any, plaintext []byte, err error) {
+	return decrypt(token, key, parseOptions{})
+}
+
+// Decrypt is Decrypt with the Verifier's Limits, accepting the "crit"
+// extensions in Critical. Algorithms and MinRSABits govern signatures only
+// and do not apply.
+func (v *Verifier) Decrypt(token string, key any) (header map[string]any, plaintext []byte, err error) {
+	return decrypt(token, key, v.parseOptions())
+}
+
+func decrypt(token string, key any, po parseOptions) (header map[string]any, plaintext []byte, err error) {
+	if err := po.checkSize(len(token)); err != nil {
+		return nil, nil, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, nil, errors.New("jwt: expected 5 segments")
+	}
+	if header, err = po.decodeHeader(parts[0]); err != nil {
+		return nil, nil, err
+	}
+	if err := checkCrit(header, po.critical); err != nil {
+		return nil, nil, err
+	}
+	var seg [4][]byte
+	for i, name := range []string{"encrypted key", "iv", "ciphertext", "tag"} {
+		if seg[i], err = decodeSegment(parts[i+1], name, po.limits.payload()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	alg, _ := header["alg"].(string)
+	enc, _ := header["enc"].(string)
+	if _, ok := header["zip"]; ok {
+		return nil, nil, errors.New("jwt: compressed jwe not supported")
+	}
+	cc, err := contentCipherFor(enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err := unwrapCEK(alg, enc, cc.keySize, key, header, seg[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err = cc.open(cek, seg[1], seg[2], seg[3], []byte(parts[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, plaintext, nil
+}
+
+// DecryptNested decrypts a JWE whose "cty" is "JWT" and verifies the JWS
+// inside it with verifyKey (see Verify). It returns the inner JWS header and
+// payload.
+func DecryptNested(token string, decryptKey, verifyKey any) (header, payload map[string]any, err error) {
+	outer, inner, err := Decrypt(token, decryptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cty, _ := outer["cty"].(string); !strings.EqualFold(cty, "JWT") {
+		return nil, nil, errors.New(`jwt: jwe content type is not "JWT"`)
+	}
+	return Verify(string(inner), verifyKey)
+}
+
+// wrapCEK picks or derives the content encryption key for alg and returns it
+// with the JWE Encrypted Key. ECDH-ES adds "epk" to hdr.
+func wrapCEK(alg, enc string, cekSize int, key any, hdr map[string]any) (cek, encryptedKey []byte, err error) {
+	switch alg {
+	case "dir":
+		secret, ok := key.([]byte)
+		if !ok || len(secret) != cekSize {
+			return nil, nil, fmt.Errorf("jwt: dir with %s requires a %d-byte []byte key", enc, cekSize)
+		}
+		return secret, nil, nil
+	case "A128KW", "A192KW", "A256KW":
+		kek, ok := key.([]byte)
+		if !ok || len(kek) != kwKeySize(alg) {
+			return nil, nil, fmt.Errorf("jwt: %s requires a %d-byte []byte key", alg, kwKeySize(alg))
+		}
+		cek = randomKey(cekSize)
+		encryptedKey, err = aesKeyWrap(kek, cek)
+		return cek, encryptedKey, err
+	case "RSA-OAEP", "RSA-OAEP-256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("jwt: %s requires *rsa.PublicKey", alg)
+		}
+		cek = randomKey(cekSize)
+		encryptedKey, err = rsa.EncryptOAEP(oaepHash(alg), rand.Reader, pub, cek, nil)
+		return cek, encryptedKey, err
+	case "ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A192KW", "ECDH-ES+A256KW":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("jwt: %s requires *ecdsa.PublicKey", alg)
+		}
+		remote, err := pub.ECDH()
+		if err != nil {
+			return nil, nil, fmt.Errorf("jwt: %s: %w", alg, err)
+		}
+		eph, err := remote.Curve().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		z, err := eph.ECDH(remote)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		point := eph.PublicKey().Bytes() // 0x04 || x || y
+		hdr["epk"] = map[string]any{
+			"kty": "EC",
+			"crv": pub.Curve.Params().Name,
+			"x":   b64urlEncode(point[1 : 1+size]),
+			"y":   b64urlEncode(point[1+size:]),
+		}
+		apu, apv, err := partyInfo(hdr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if alg == "ECDH-ES" {
+			return concatKDF(z, enc, apu, apv, cekSize), nil, nil
+		}
+		kwAlg := strings.TrimPrefix(alg, "ECDH-ES+")
+		kek := concatKDF(z, alg, apu, apv, kwKeySize(kwAlg))
+		cek = randomKey(cekSize)
+		encryptedKey, err = aesKeyWrap(kek, cek)
+		return cek, encryptedKey, err
+	default:
+		return nil, nil, fmt.Errorf("jwt: unsupported jwe alg %q", alg)
+	}
+}
+
+// unwrapCEK recovers the content encryption key. Failures after key
+// decryption surface as errDecrypt from the content decryption.
+func unwrapCEK(alg, enc string, cekSize int, key any, hdr map[string]any, encryptedKey []byte) ([]byte, error) {
+	switch alg {
+	case "dir":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, errors.New("jwt: dir requires a []byte key")
+		}
+		if len(encryptedKey) != 0 {
+			return nil, errors.New("jwt: dir must have an empty encrypted key")
+		}
+		return secret, nil
+	case "A128KW", "A192KW", "A256KW":
+		kek, ok := key.([]byte)
+		if !ok || len(kek) != kwKeySize(alg) {
+			return nil, fmt.Errorf("jwt: %s requires a %d-byte []byte key", alg, kwKeySize(alg))
+		}
+		return aesKeyUnwrap(kek, encryptedKey)
+	case "RSA-OAEP", "RSA-OAEP-256":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("jwt: %s requires *rsa.PrivateKey", alg)
+		}
+		cek, err := rsa.DecryptOAEP(oaepHash(alg), nil, priv, encryptedKey, nil)
+		if err != nil || len(cek) != cekSize {
+			// Continue with a random key so that a bad encrypted key and a
+			// bad ciphertext fail the same way (RFC 7516 section 11.5).
+			return randomKey(cekSize), nil
+		}
+		return cek, nil
+	case "ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A192KW", "ECDH-ES+A256KW":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("jwt: %s requires *ecdsa.PrivateKey", alg)
+		}
+		z, err := ecdhSharedSecret(priv, hdr)
+		if err != nil {
+			return nil, err
+		}
+		apu, apv, err := partyInfo(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if alg == "ECDH-ES" {
+			if len(encryptedKey) != 0 {
+				return nil, errors.New("jwt: ECDH-ES must have an empty encrypted key")
+			}
+			return concatKDF(z, enc, apu, apv, cekSize), nil
+		}
+		kwAlg := strings.TrimPrefix(alg, "ECDH-ES+")
+		return aesKeyUnwrap(concatKDF(z, alg, apu, apv, kwKeySize(kwAlg)), encryptedKey)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported jwe alg %q", alg)
+	}
+}
+
+// ecdhSharedSecret computes Z from priv and the header's "epk". crypto/ecdh
+// validates the point, which rules out invalid-curve attacks.
+func ecdhSharedSecret(priv *ecdsa.PrivateKey, hdr map[string]any) ([]byte, error) {
+	raw, err := json.Marshal(hdr["epk"])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: invalid epk: %w", err)
+	}
+	var jwk rawJWK
+	if err := json.Unmarshal(raw, &jwk); err != nil || jwk.Kty != "EC" {
+		return nil, errors.New("jwt: epk must be an EC key")
+	}
+	k, err := jwk.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	epk := k.(*ecdsa.PublicKey)
+	if epk.Curve != priv.Curve {
+		return nil, errors.New("jwt: epk curve does not match key")
+	}
+	remote, err := epk.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	local, err := priv.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	return local.ECDH(remote)
+}
+
+// partyInfo returns the decoded "apu" and "apv" header parameters.
+func partyInfo(hdr map[string]any) (apu, apv []byte, err error) {
+	for name, dst := range map[string]*[]byte{"apu": &apu, "apv": &apv} {
+		s, _ := hdr[name].(string)
+		if *dst, err = b64urlDecode(s); err != nil {
+			return nil, nil, fmt.Errorf("jwt: decode %s: %w", name, err)
+		}
+	}
+	return apu, apv, nil
+}
+
+func kwKeySize(alg string) int {
+	switch alg {
+	case "A128KW":
+		return 16
+	case "A192KW":
+		return 24
+	default:
+		return 32
+	}
+}
+
+func oaepHash(alg string) hash.Hash {
+	if alg == "RSA-OAEP" {
+		return sha1.New()
+	}
+	return sha256.New()
+}
+
+func randomKey(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,152 @@
+package jwtverify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p521, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	k16, k32, k64 := randomKey(16), randomKey(32), randomKey(64)
+	plaintext := []byte("the quick brown fox")
+
+	for _, tc := range []struct {
+		alg, enc  string
+		pub, priv any
+	}{
+		{"RSA-OAEP", "A128GCM", &rsaKey.PublicKey, rsaKey},
+		{"RSA-OAEP-256", "A256GCM", &rsaKey.PublicKey, rsaKey},
+		{"RSA-OAEP-256", "A128CBC-HS256", &rsaKey.PublicKey, rsaKey},
+		{"ECDH-ES", "A256GCM", &p256.PublicKey, p256},
+		{"ECDH-ES", "A128CBC-HS256", &p521.PublicKey, p521},
+		{"ECDH-ES+A128KW", "A128GCM", &p256.PublicKey, p256},
+		{"ECDH-ES+A256KW", "A256CBC-HS512", &p521.PublicKey, p521},
+		{"A128KW", "A128GCM", k16, k16},
+		{"A256KW", "A128CBC-HS256", k32, k32},
+		{"dir", "A256GCM", k32, k32},
+		{"dir", "A256CBC-HS512", k64, k64},
+	} {
+		hdr := map[string]any{"alg": tc.alg, "enc": tc.enc}
+		if strings.HasPrefix(tc.alg, "ECDH") {
+			hdr["apu"], hdr["apv"] = b64url([]byte("alice")), b64url([]byte("bob"))
+		}
+		tok, err := Encrypt(hdr, plaintext, tc.pub)
+		if err != nil {
+			t.Fatalf("%s/%s: Encrypt: %v", tc.alg, tc.enc, err)
+		}
+		if n := strings.Count(tok, "."); n != 4 {
+			t.Fatalf("%s/%s: %d dots", tc.alg, tc.enc, n)
+		}
+		h, pt, err := Decrypt(tok, tc.priv)
+		if err != nil {
+			t.Fatalf("%s/%s: Decrypt: %v", tc.alg, tc.enc, err)
+		}
+		if !bytes.Equal(pt, plaintext) || h["enc"] != tc.enc {
+			t.Fatalf("%s/%s: got %q, header %v", tc.alg, tc.enc, pt, h)
+		}
+
+		// Flip a ciphertext bit.
+		parts := strings.Split(tok, ".")
+		ct, _ := b64urlDecode(parts[3])
+		ct[0] ^= 1
+		parts[3] = b64url(ct)
+		if _, _, err := Decrypt(strings.Join(parts, "."), tc.priv); err == nil {
+			t.Fatalf("%s/%s: tampered ciphertext accepted", tc.alg, tc.enc)
+		}
+	}
+}
+
+func TestDecrypt_RFC7516AppendixA3(t *testing.T) {
+	key, _ := b64urlDecode("GawgguFyGrWKav7AX4VKUg")
+	tok := "eyJhbGciOiJBMTI4S1ciLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0." +
+		"6KB707dM9YTIgHtLvtgWQ8mKwboJW3of9locizkDTHzBC2IlrT1oOQ." +
+		"AxY8DCtDaGlsbGljb3RoZQ." +
+		"KDlTtXchhZTGufMYmOYGS4HffxPSUrfmqCHXaI9wOGY." +
+		"U0m_YmjN04DJvceFICbCVQ"
+	_, pt, err := Decrypt(tok, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pt) != "Live long and prosper." {
+		t.Fatalf("plaintext = %q", pt)
+	}
+}
+
+func TestAESKeyWrap_RFC3394(t *testing.T) {
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	cek, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	want, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+	got, err := aesKeyWrap(kek, cek)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("wrap = %X, %v", got, err)
+	}
+	back, err := aesKeyUnwrap(kek, got)
+	if err != nil || !bytes.Equal(back, cek) {
+		t.Fatalf("unwrap = %X, %v", back, err)
+	}
+	got[0] ^= 1
+	if _, err := aesKeyUnwrap(kek, got); err == nil {
+		t.Fatal("integrity check passed for corrupted input")
+	}
+}
+
+func TestDecryptNested(t *testing.T) {
+	_, signKey, _ := ed25519.GenerateKey(rand.Reader)
+	encKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	jws, err := Sign(nil, map[string]any{"sub": "nested"}, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Encrypt(map[string]any{"alg": "ECDH-ES+A256KW", "cty": "JWT"}, []byte(jws), &encKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, p, err := DecryptNested(tok, encKey, signKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h["alg"] != "EdDSA" || p["sub"] != "nested" {
+		t.Fatalf("header %v payload %v", h, p)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, _, err := DecryptNested(tok, other, signKey.Public()); err == nil {
+		t.Fatal("decrypted with the wrong key")
+	}
+	plain, _ := Encrypt(map[string]any{"alg": "ECDH-ES"}, []byte(jws), &encKey.PublicKey)
+	if _, _, err := DecryptNested(plain, encKey, signKey.Public()); err == nil {
+		t.Fatal("accepted JWE without cty JWT")
+	}
+}
+
+func TestConcatKDF_RFC7518AppendixC(t *testing.T) {
+	priv := func(x, y, d string) *ecdsa.PrivateKey {
+		bx, _ := b64urlDecode(x)
+		by, _ := b64urlDecode(y)
+		bd, _ := b64urlDecode(d)
+		k := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(bd)}
+		k.Curve, k.X, k.Y = elliptic.P256(), new(big.Int).SetBytes(bx), new(big.Int).SetBytes(by)
+		return k
+	}
+	alice := priv("gI0GAILBdu7T53akrFmMyGcsF3n5dO7MmwNBHKW5SV0", "SLW_xSffzlPWrHEVI30DHM_4egVwt3NQqeUD7nMFpps", "0_NxaRPUMQoAJt50Gz8YiTr8gRTwyEaCumd-MToTmIo")
+	bob := priv("weNJy2HscCSM6AEDTDg04biOvhFhyyWvOHQfeF_PxMQ", "e8lnCO-AlStT-NJVX-crhB7QRYhiix03illJOVAOyck", "VEmDZpDXXK8p8N0Cndsxs924q6nS1RXFASRl6BfUqdw")
+
+	z, err := ecdhSharedSecret(bob, map[string]any{"epk": jwkJSON("", &alice.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := concatKDF(z, "A128GCM", []byte("Alice"), []byte("Bob"), 16)
+	if b64url(got) != "VqqN6vgjbSBcIijNcacQGg" {
+		t.Fatalf("derived key = %s", b64url(got))
+	}
+}
@@ -0,0 +1,215 @@
+package jwtverify
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// errDecrypt is deliberately vague so that failures do not act as an oracle.
+var errDecrypt = errors.New("jwt: jwe decryption failed")
+
+// contentCipher implements a JWE "enc" algorithm (RFC 7518 section 5).
+type contentCipher struct {
+	keySize int // CEK bytes
+	ivSize  int
+	gcm     bool
+	hash    func() hash.Hash // CBC-HS only
+}
+
+func contentCipherFor(enc string) (contentCipher, error) {
+	switch enc {
+	case "A128GCM":
+		return contentCipher{keySize: 16, ivSize: 12, gcm: true}, nil
+	case "A192GCM":
+		return contentCipher{keySize: 24, ivSize: 12, gcm: true}, nil
+	case "A256GCM":
+		return contentCipher{keySize: 32, ivSize: 12, gcm: true}, nil
+	case "A128CBC-HS256":
+		return contentCipher{keySize: 32, ivSize: 16, hash: sha256.New}, nil
+	case "A192CBC-HS384":
+		return contentCipher{keySize: 48, ivSize: 16, hash: sha512.New384}, nil
+	case "A256CBC-HS512":
+		return contentCipher{keySize: 64, ivSize: 16, hash: sha512.New}, nil
+	default:
+		return contentCipher{}, fmt.Errorf("jwt: unsupported enc %q", enc)
+	}
+}
+
+func (c contentCipher) seal(cek, iv, plaintext, aad []byte) (ciphertext, tag []byte, err error) {
+	if c.gcm {
+		aead, err := newGCM(cek)
+		if err != nil {
+			return nil, nil, err
+		}
+		out := aead.Seal(nil, iv, plaintext, aad)
+		n := len(out) - aead.Overhead()
+		return out[:n], out[n:], nil
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext = make([]byte, len(plaintext)+pad)
+	copy(ciphertext, plaintext)
+	for i := len(plaintext); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return ciphertext, c.cbcTag(macKey, aad, iv, ciphertext), nil
+}
+
+func (c contentCipher) open(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if len(cek) != c.keySize || len(iv) != c.ivSize {
+		return nil, errDecrypt
+	}
+	if c.gcm {
+		aead, err := newGCM(cek)
+		if err != nil {
+			return nil, err
+		}
+		if len(tag) != aead.Overhead() {
+			return nil, errDecrypt
+		}
+		pt, err := aead.Open(nil, iv, append(ciphertext[:len(ciphertext):len(ciphertext)], tag...), aad)
+		if err != nil {
+			return nil, errDecrypt
+		}
+		return pt, nil
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	// Authenticate before decrypting (no padding oracle).
+	if !hmac.Equal(tag, c.cbcTag(macKey, aad, iv, ciphertext)) {
+		return nil, errDecrypt
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errDecrypt
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	pt := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(pt, ciphertext)
+	pad := int(pt[len(pt)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errDecrypt
+	}
+	for _, b := range pt[len(pt)-pad:] {
+		if int(b) != pad {
+			return nil, errDecrypt
+		}
+	}
+	return pt[:len(pt)-pad], nil
+}
+
+// cbcTag computes the AES_CBC_HMAC_SHA2 authentication tag (RFC 7518
+// section 5.2.2.1): the first half of HMAC(AAD || IV || C || AL).
+func (c contentCipher) cbcTag(macKey, aad, iv, ciphertext []byte) []byte {
+	mac := hmac.New(c.hash, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(len(aad))*8)
+	mac.Write(al[:])
+	return mac.Sum(nil)[:len(macKey)]
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap implements RFC 3394 AES Key Wrap.
+func aesKeyWrap(kek, cek []byte) ([]byte, error) {
+	if len(cek) < 16 || len(cek)%8 != 0 {
+		return nil, errors.New("jwt: key wrap: invalid key length")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(cek) / 8
+	out := make([]byte, 8+len(cek))
+	copy(out, keyWrapIV)
+	copy(out[8:], cek)
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap reverses aesKeyWrap and checks the integrity value.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errDecrypt
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(b[8:], out[8*i:8*i+8])
+			block.Decrypt(b[:], b[:])
+			copy(out[:8], b[:8])
+			copy(out[8*i:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], keyWrapIV) != 1 {
+		return nil, errDecrypt
+	}
+	return out[8:], nil
+}
+
+// concatKDF derives keyLen bytes from the ECDH shared secret z per the
+// Concat KDF of NIST SP 800-56A as profiled by RFC 7518 section 4.6.2.
+func concatKDF(z []byte, algID string, apu, apv []byte, keyLen int) []byte {
+	lenPrefixed := func(b []byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(b))), b...)
+	}
+	var other []byte
+	other = append(other, lenPrefixed([]byte(algID))...)
+	other = append(other, lenPrefixed(apu)...)
+	other = append(other, lenPrefixed(apv)...)
+	other = binary.BigEndian.AppendUint32(other, uint32(keyLen*8))
+
+	var out []byte
+	for counter := uint32(1); len(out) < keyLen; counter++ {
+		h := sha256.New()
+		h.Write(binary.BigEndian.AppendUint32(nil, counter))
+		h.Write(z)
+		h.Write(other)
+		out = h.Sum(out)
+	}
+	return out[:keyLen]
+}
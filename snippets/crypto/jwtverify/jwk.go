@@ -0,0 +1,265 @@
+package jwtverify
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a key from a JSON Web Key (RFC 7517). It marshals to and from its
+// JSON form; only public key material is ever marshaled.
+type JWK struct {
+	KeyID     string // "kid"
+	Algorithm string // "alg", optional
+	Use       string // "use", optional ("sig" or "enc")
+	// Key is *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, or a
+	// []byte secret for "oct" keys parsed with ParseJWK.
+	Key any
+}
+
+// ParseJWK parses a single JWK. Besides the public key types of ParseJWKS it
+// accepts symmetric "oct" keys, returned as []byte. Private key members of
+// asymmetric keys are ignored.
+func ParseJWK(data []byte) (JWK, error) {
+	var raw rawJWK
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return JWK{}, fmt.Errorf("jwt: parse jwk: %w", err)
+	}
+	var key any
+	var err error
+	if raw.Kty == "oct" {
+		key, err = b64urlDecode(raw.K)
+		if err == nil && len(raw.K) == 0 {
+			err = errors.New("jwt: jwk: empty oct key")
+		}
+	} else {
+		key, err = raw.publicKey()
+	}
+	if err != nil {
+		return JWK{}, err
+	}
+	return JWK{KeyID: raw.Kid, Algorithm: raw.Alg, Use: raw.Use, Key: key}, nil
+}
+
+// NewJWK returns a JWK for the public half of key (a public or private RSA,
+// ECDSA or Ed25519 key) with its RFC 7638 thumbprint as KeyID.
+func NewJWK(key any) (JWK, error) {
+	pub := key
+	if p := publicKeyOf(key); p != nil {
+		pub = p
+	}
+	kid, err := Thumbprint(pub)
+	if err != nil {
+		return JWK{}, err
+	}
+	return JWK{KeyID: kid, Key: pub}, nil
+}
+
+// MarshalJSON encodes the public key as a JWK. Private keys are exported as
+// their public half; symmetric keys are refused.
+func (k JWK) MarshalJSON() ([]byte, error) {
+	raw, err := publicRawJWK(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	raw.Kid, raw.Alg, raw.Use = k.KeyID, k.Algorithm, k.Use
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON parses a JWK like ParseJWK.
+func (k *JWK) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseJWK(data)
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// MarshalJWKS encodes keys as a JWK Set, e.g. to serve a JWKS endpoint.
+func MarshalJWKS(keys ...JWK) ([]byte, error) {
+	if keys == nil {
+		keys = []JWK{}
+	}
+	return json.Marshal(struct {
+		Keys []JWK `json:"keys"`
+	}{keys})
+}
+
+// Thumbprint computes the RFC 7638 JWK thumbprint (SHA-256, base64url) of a
+// public or private RSA, ECDSA or Ed25519 key, suitable as a "kid".
+func Thumbprint(key any) (string, error) {
+	pub := key
+	if p := publicKeyOf(key); p != nil {
+		pub = p
+	}
+	raw, err := publicRawJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	// Only the required members, in lexicographic order, without whitespace.
+	var members string
+	switch raw.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, raw.E, raw.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, raw.Crv, raw.X, raw.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, raw.Crv, raw.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64urlEncode(sum[:]), nil
+}
+
+// publicRawJWK returns the JWK members of a public key.
+func publicRawJWK(key any) (rawJWK, error) {
+	if p := publicKeyOf(key); p != nil {
+		key = p
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return rawJWK{Kty: "RSA", N: b64urlEncode(k.N.Bytes()), E: b64urlEncode(big.NewInt(int64(k.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		if _, _, err := curveByName(k.Curve.Params().Name); err != nil {
+			return rawJWK{}, err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return rawJWK{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   b64urlEncode(k.X.FillBytes(make([]byte, size))),
+			Y:   b64urlEncode(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return rawJWK{Kty: "OKP", Crv: "Ed25519", X: b64urlEncode(k)}, nil
+	default:
+		return rawJWK{}, fmt.Errorf("jwt: jwk: unsupported key type %T", key)
+	}
+}
+
+// rawJWK holds the JSON members of a JWK that this package understands.
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"` // oct
+}
+
+// ParseJWKS parses a JWK Set ({"keys": [...]}). Keys with an unsupported
+// "kty" or "crv", or that are malformed, are skipped as RFC 7517 section 5
+// recommends.
+func ParseJWKS(data []byte) ([]JWK, error) {
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: parse jwks: %w", err)
+	}
+	keys := make([]JWK, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		k, err := raw.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, JWK{KeyID: raw.Kid, Algorithm: raw.Alg, Use: raw.Use, Key: k})
+	}
+	return keys, nil
+}
+
+func (r rawJWK) publicKey() (any, error) {
+	switch r.Kty {
+	case "RSA":
+		n, err := b64urlDecode(r.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("jwt: jwk: invalid RSA modulus")
+		}
+		e, err := b64urlDecode(r.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("jwt: jwk: invalid RSA exponent")
+		}
+		exp := int(new(big.Int).SetBytes(e).Int64())
+		if exp < 3 || exp%2 == 0 {
+			return nil, errors.New("jwt: jwk: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	case "EC":
+		curve, ecdhCurve, err := curveByName(r.Crv)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, errX := b64urlDecode(r.X)
+		y, errY := b64urlDecode(r.Y)
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("jwt: jwk: invalid EC coordinates")
+		}
+		// crypto/ecdh rejects points that are not on the curve.
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("jwt: jwk: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if r.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwt: jwk: unsupported OKP curve %q", r.Crv)
+		}
+		x, err := b64urlDecode(r.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwt: jwk: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("jwt: jwk: unsupported kty %q", r.Kty)
+	}
+}
+
+func curveByName(crv string) (elliptic.Curve, ecdh.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), ecdh.P256(), nil
+	case "P-384":
+		return elliptic.P384(), ecdh.P384(), nil
+	case "P-521":
+		return elliptic.P521(), ecdh.P521(), nil
+	default:
+		return nil, nil, fmt.Errorf("jwt: jwk: unsupported curve %q", crv)
+	}
+}
+
+// keyFitsAlg reports whether key can verify signatures made with alg.
+func keyFitsAlg(key any, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			return true
+		}
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return k.Curve == elliptic.P256()
+		case "ES384":
+			return k.Curve == elliptic.P384()
+		case "ES512":
+			return k.Curve == elliptic.P521()
+		}
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	case []byte:
+		return alg == "HS256" || alg == "HS384" || alg == "HS512"
+	}
+	return false
+}
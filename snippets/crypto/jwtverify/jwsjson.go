@@ -0,0 +1,339 @@
+package jwtverify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// jwsJSON is the general and flattened JWS JSON serialization (RFC 7515
+// section 7.2).
+type jwsJSON struct {
+	Payload    *string        `json:"payload,omitempty"`
+	Protected  string         `json:"protected,omitempty"`
+	Header     map[string]any `json:"header,omitempty"`
+	Signature  string         `json:"signature,omitempty"`
+	Signatures []jwsSignature `json:"signatures,omitempty"`
+}
+
+type jwsSignature struct {
+	Protected string         `json:"protected,omitempty"`
+	Header    map[string]any `json:"header,omitempty"`
+	Signature string         `json:"signature"`
+}
+
+// VerifyJSON verifies a JWS in general or flattened JSON serialization and
+// returns the payload with the joint (protected + unprotected) header of the
+// first signature that key verifies.
+//
+// For detached content (RFC 7515 appendix F) pass the payload as detached;
+// the JWS must then omit "payload". Unencoded payloads ("b64": false, RFC
+// 7797) are supported; "crit" may only list "b64" (use Verifier.VerifyJSON to
+// register others). Sizes are bounded by the default Limits.
+func VerifyJSON(data []byte, detached []byte, key any) (header map[string]any, payload []byte, err error) {
+	return verifyJSON(data, detached, key, nil)
+}
+
+// VerifyJSON is VerifyJSON with the Verifier's policy: each signature's alg
+// and key are checked as in Verify, and Limits and Critical apply.
+func (v *Verifier) VerifyJSON(data []byte, detached []byte, key any) (header map[string]any, payload []byte, err error) {
+	return verifyJSON(data, detached, key, v)
+}
+
+// verifyJSON implements VerifyJSON; v is nil for the package-level function.
+func verifyJSON(data []byte, detached []byte, key any, v *Verifier) (header map[string]any, payload []byte, err error) {
+	po := v.parseOptions()
+	if err := po.checkSize(len(data)); err != nil {
+		return nil, nil, err
+	}
+	var j jwsJSON
+	if err := unmarshalStrict(data, &j); err != nil {
+		return nil, nil, fmt.Errorf("jwt: parse jws json: %w", err)
+	}
+	sigs := j.Signatures
+	if sigs == nil {
+		sigs = []jwsSignature{{Protected: j.Protected, Header: j.Header, Signature: j.Signature}}
+	} else if j.Protected != "" || j.Header != nil || j.Signature != "" {
+		return nil, nil, errors.New("jwt: jws json mixes general and flattened syntax")
+	}
+	if len(sigs) == 0 {
+		return nil, nil, errors.New("jwt: jws json has no signatures")
+	}
+
+	var rawPayload string // as it appears in the signing input, minus encoding
+	switch {
+	case detached != nil && j.Payload != nil && *j.Payload != "":
+		return nil, nil, errors.New("jwt: jws has both attached and detached payload")
+	case detached == nil && j.Payload == nil:
+		return nil, nil, errors.New("jwt: jws payload is detached")
+	}
+
+	var firstB64 bool
+	err = errors.New("jwt: no signature verified")
+	for i, s := range sigs {
+		hdr, b64, herr := jointHeader(s.Protected, s.Header, po)
+		if herr != nil {
+			return nil, nil, fmt.Errorf("jwt: signature %d: %w", i, herr)
+		}
+		if i == 0 {
+			firstB64 = b64
+			if payload, rawPayload, herr = payloadForSigning(j.Payload, detached, b64, po); herr != nil {
+				return nil, nil, herr
+			}
+		} else if b64 != firstB64 {
+			// RFC 7797 section 6: all signatures must use the same "b64".
+			return nil, nil, errors.New(`jwt: signatures disagree on "b64"`)
+		}
+		alg, _ := hdr["alg"].(string)
+		if alg == "" || alg == "none" {
+			err = fmt.Errorf("jwt: signature %d: missing or disallowed alg", i)
+			continue
+		}
+		k := key
+		if v != nil {
+			if k, herr = v.checkKey(alg, key); herr != nil {
+				err = fmt.Errorf("jwt: signature %d: %w", i, herr)
+				continue
+			}
+		}
+		sig, derr := b64urlDecode(s.Signature)
+		if derr != nil {
+			err = fmt.Errorf("jwt: signature %d: decode signature: %w", i, derr)
+			continue
+		}
+		if verr := verifyJWS(alg, s.Protected+"."+rawPayload, sig, k); verr != nil {
+			err = verr
+			continue
+		}
+		return hdr, payload, nil
+	}
+	return nil, nil, err
+}
+
+// VerifyDetached verifies a compact JWS whose payload is detached
+// ("header..signature") against payload, honouring "b64": false. It
+// returns the header.
+func VerifyDetached(token string, payload []byte, key any) (map[string]any, error) {
+	return verifyDetached(token, payload, key, nil)
+}
+
+// VerifyDetached is VerifyDetached with the Verifier's policy, as in Verify.
+func (v *Verifier) VerifyDetached(token string, payload []byte, key any) (map[string]any, error) {
+	return verifyDetached(token, payload, key, v)
+}
+
+func verifyDetached(token string, payload []byte, key any, v *Verifier) (map[string]any, error) {
+	po := v.parseOptions()
+	if err := po.checkSize(len(token)); err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: expected 3 segments")
+	}
+	if parts[1] != "" {
+		return nil, errors.New("jwt: payload is not detached")
+	}
+	hdr, b64, err := jointHeader(parts[0], nil, po)
+	if err != nil {
+		return nil, err
+	}
+	alg, _ := hdr["alg"].(string)
+	if alg == "" || alg == "none" {
+		return nil, errors.New("jwt: missing or disallowed alg")
+	}
+	if v != nil {
+		if key, err = v.checkKey(alg, key); err != nil {
+			return nil, err
+		}
+	}
+	sig, err := b64urlDecode(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: decode signature: %w", err)
+	}
+	p := string(payload)
+	if b64 {
+		p = b64urlEncode(payload)
+	}
+	if err := verifyJWS(alg, parts[0]+"."+p, sig, key); err != nil {
+		return nil, err
+	}
+	return hdr, nil
+}
+
+// JSONSigner is one signer of SignJSON.
+type JSONSigner struct {
+	// Key is a signing key as for Sign.
+	Key any
+	// Protected is the integrity-protected header. "alg" defaults as in Sign.
+	Protected map[string]any
+	// Header holds unprotected header parameters (e.g. "kid").
+	Header map[string]any
+}
+
+// JSONOptions configures SignJSON.
+type JSONOptions struct {
+	// Flattened produces the flattened syntax; it requires a single signer.
+	Flattened bool
+	// Detached omits the payload from the output.
+	Detached bool
+	// Unencoded signs the payload as-is ("b64": false, RFC 7797) and marks the
+	// extension critical. With Unencoded the attached payload must be valid
+	// UTF-8 JSON string content, so combine it with Detached for binary data.
+	Unencoded bool
+}
+
+// SignJSON produces a JWS JSON serialization of payload with one signature
+// per signer.
+func SignJSON(payload []byte, opt JSONOptions, signers ...JSONSigner) ([]byte, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("jwt: no signers")
+	}
+	if opt.Flattened && len(signers) != 1 {
+		return nil, errors.New("jwt: flattened syntax needs exactly one signer")
+	}
+	encPayload := b64urlEncode(payload)
+	if opt.Unencoded {
+		encPayload = string(payload)
+	}
+
+	var out jwsJSON
+	for _, s := range signers {
+		prot := maps.Clone(s.Protected)
+		if prot == nil {
+			prot = map[string]any{}
+		}
+		alg, _ := prot["alg"].(string)
+		if alg == "" {
+			alg, _ = s.Header["alg"].(string)
+		}
+		if alg == "" {
+			var err error
+			if alg, err = defaultAlg(s.Key); err != nil {
+				return nil, err
+			}
+			prot["alg"] = alg
+		}
+		if opt.Unencoded {
+			prot["b64"] = false
+			crit, err := critNames(prot["crit"])
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(crit, "b64") {
+				crit = append(crit, "b64")
+			}
+			prot["crit"] = crit
+		}
+		for name := range s.Header {
+			if _, dup := prot[name]; dup {
+				return nil, fmt.Errorf("jwt: header parameter %q is both protected and unprotected", name)
+			}
+		}
+		rawProt, err := json.Marshal(prot)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: encode header: %w", err)
+		}
+		encProt := b64urlEncode(rawProt)
+		sig, err := signJWS(alg, encProt+"."+encPayload, s.Key)
+		if err != nil {
+			return nil, err
+		}
+		out.Signatures = append(out.Signatures, jwsSignature{Protected: encProt, Header: s.Header, Signature: b64urlEncode(sig)})
+	}
+	if !opt.Detached {
+		out.Payload = &encPayload
+	}
+	if opt.Flattened {
+		s := out.Signatures[0]
+		out.Protected, out.Header, out.Signature, out.Signatures = s.Protected, s.Header, s.Signature, nil
+	}
+	return json.Marshal(out)
+}
+
+// critNames copies a caller-supplied "crit" value, which may be a []string or
+// a decoded []any of strings.
+func critNames(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return slices.Clone(v), nil
+	case []any:
+		names := make([]string, 0, len(v))
+		for _, n := range v {
+			s, ok := n.(string)
+			if !ok {
+				return nil, errors.New(`jwt: "crit" must contain strings`)
+			}
+			names = append(names, s)
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf(`jwt: "crit" must be an array of strings, got %T`, v)
+}
+
+// jointHeader decodes a protected header, merges the unprotected one (names
+// must be disjoint), validates "crit" and reports whether the payload is
+// base64url-encoded.
+func jointHeader(encProtected string, unprotected map[string]any, po parseOptions) (hdr map[string]any, b64 bool, err error) {
+	protected := map[string]any{}
+	if encProtected != "" {
+		if protected, err = po.decodeHeader(encProtected); err != nil {
+			return nil, false, err
+		}
+	}
+	hdr = maps.Clone(protected)
+	for name, v := range unprotected {
+		if _, dup := hdr[name]; dup {
+			return nil, false, fmt.Errorf("jwt: header parameter %q is both protected and unprotected", name)
+		}
+		hdr[name] = v
+	}
+	if _, ok := unprotected["crit"]; ok {
+		return nil, false, errors.New(`jwt: "crit" must be protected`)
+	}
+	if _, ok := unprotected["b64"]; ok {
+		return nil, false, errors.New(`jwt: "b64" must be protected`)
+	}
+	if err := checkCrit(protected, slices.Concat(understoodCrit, po.critical)); err != nil {
+		return nil, false, err
+	}
+	b64 = true
+	if v, ok := protected["b64"]; ok {
+		bv, isBool := v.(bool)
+		if !isBool {
+			return nil, false, errors.New(`jwt: "b64" must be a boolean`)
+		}
+		if !bv && !critLists(protected, "b64") {
+			return nil, false, errors.New(`jwt: "b64": false must be listed in "crit"`)
+		}
+		b64 = bv
+	}
+	return hdr, b64, nil
+}
+
+// payloadForSigning returns the payload bytes and their form in the signing
+// input.
+func payloadForSigning(attached *string, detached []byte, b64 bool, po parseOptions) ([]byte, string, error) {
+	if detached != nil {
+		if b64 {
+			return detached, b64urlEncode(detached), nil
+		}
+		return detached, string(detached), nil
+	}
+	if !b64 {
+		if len(*attached) > po.limits.payload() {
+			return nil, "", fmt.Errorf("%w: payload is %d bytes, max %d", ErrTokenTooLarge, len(*attached), po.limits.payload())
+		}
+		return []byte(*attached), *attached, nil
+	}
+	p, err := decodeSegment(*attached, "payload", po.limits.payload())
+	if err != nil {
+		return nil, "", err
+	}
+	return p, *attached, nil
+}
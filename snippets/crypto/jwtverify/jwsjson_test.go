@@ -0,0 +1,150 @@
+package jwtverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDetached_RFC7797(t *testing.T) {
+	key, _ := b64urlDecode("AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow")
+	payload := []byte("$.02")
+
+	// Section 4.1 (b64 true) and 4.2 (b64 false).
+	for _, tok := range []string{
+		"eyJhbGciOiJIUzI1NiJ9..5mvfOroL-g7HyqJoozehmsaqmvTYGEq5jTI1gVvoEoQ",
+		"eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY",
+	} {
+		if _, err := VerifyDetached(tok, payload, key); err != nil {
+			t.Fatalf("%s: %v", tok, err)
+		}
+		if _, err := VerifyDetached(tok, []byte("$.03"), key); err == nil {
+			t.Fatalf("%s: wrong payload accepted", tok)
+		}
+		if _, err := (&Verifier{Algorithms: []string{"HS256"}}).VerifyDetached(tok, payload, key); err != nil {
+			t.Fatalf("%s: verifier: %v", tok, err)
+		}
+		if _, err := (&Verifier{Algorithms: []string{"ES256"}}).VerifyDetached(tok, payload, key); !errors.Is(err, ErrAlgNotAllowed) {
+			t.Fatalf("%s: disallowed alg: %v", tok, err)
+		}
+		small := &Verifier{Algorithms: []string{"HS256"}, Limits: Limits{MaxTokenSize: 32}}
+		if _, err := small.VerifyDetached(tok, payload, key); !errors.Is(err, ErrTokenTooLarge) {
+			t.Fatalf("%s: limits: %v", tok, err)
+		}
+	}
+}
+
+func TestSignJSON_GeneralAndFlattened(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	payload := []byte(`{"doc":"contract.pdf","sha256":"abc"}`)
+
+	for _, opt := range []JSONOptions{{}, {Detached: true}, {Unencoded: true}, {Detached: true, Unencoded: true}} {
+		data, err := SignJSON(payload, opt,
+			JSONSigner{Key: ecKey, Header: map[string]any{"kid": "ec"}},
+			JSONSigner{Key: edKey, Header: map[string]any{"kid": "ed"}},
+		)
+		if err != nil {
+			t.Fatalf("%+v: %v", opt, err)
+		}
+		var detached []byte
+		if opt.Detached {
+			detached = payload
+		}
+		for kid, pub := range map[string]any{"ec": &ecKey.PublicKey, "ed": edKey.Public()} {
+			hdr, got, err := VerifyJSON(data, detached, pub)
+			if err != nil {
+				t.Fatalf("%+v %s: %v\n%s", opt, kid, err, data)
+			}
+			if hdr["kid"] != kid || string(got) != string(payload) {
+				t.Fatalf("%+v %s: header %v payload %q", opt, kid, hdr, got)
+			}
+		}
+		if opt.Detached {
+			if _, _, err := VerifyJSON(data, []byte("tampered"), edKey.Public()); err == nil {
+				t.Fatalf("%+v: tampered detached payload accepted", opt)
+			}
+			if _, _, err := VerifyJSON(data, nil, edKey.Public()); err == nil {
+				t.Fatalf("%+v: missing detached payload accepted", opt)
+			}
+		}
+	}
+
+	flat, err := SignJSON(payload, JSONOptions{Flattened: true}, JSONSigner{Key: edKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	json.Unmarshal(flat, &m)
+	if _, ok := m["signatures"]; ok || m["signature"] == nil {
+		t.Fatalf("not flattened: %s", flat)
+	}
+	if _, got, err := VerifyJSON(flat, nil, edKey.Public()); err != nil || string(got) != string(payload) {
+		t.Fatalf("flattened: %q %v", got, err)
+	}
+	// A caller's own crit extensions are kept alongside "b64", whether the
+	// header was built in code or decoded from JSON.
+	v := &Verifier{Algorithms: []string{"EdDSA"}, Critical: []string{"ext"}}
+	for _, crit := range []any{[]string{"ext"}, []any{"ext"}} {
+		data, err := SignJSON(payload, JSONOptions{Unencoded: true},
+			JSONSigner{Key: edKey, Protected: map[string]any{"crit": crit, "ext": 1}})
+		if err != nil {
+			t.Fatalf("%T: %v", crit, err)
+		}
+		if hdr, _, err := v.VerifyJSON(data, nil, edKey.Public()); err != nil || len(hdr["crit"].([]any)) != 2 {
+			t.Fatalf("%T: %v %v", crit, hdr, err)
+		}
+	}
+	for _, crit := range []any{"ext", []any{1}} {
+		if _, err := SignJSON(payload, JSONOptions{Unencoded: true}, JSONSigner{Key: edKey, Protected: map[string]any{"crit": crit}}); err == nil {
+			t.Errorf("crit %#v accepted", crit)
+		}
+	}
+}
+
+func TestVerifyJSON_Crit(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	sign := func(protected, unprotected map[string]any) []byte {
+		enc := b64url(mustJSON(t, protected))
+		payload := b64url([]byte("x"))
+		sig := ed25519.Sign(key, []byte(enc+"."+payload))
+		return mustJSON(t, map[string]any{"payload": payload, "protected": enc, "header": unprotected, "signature": b64url(sig)})
+	}
+
+	for name, tc := range map[string]struct {
+		protected, unprotected map[string]any
+		wantErr                string
+	}{
+		"ok":               {map[string]any{"alg": "EdDSA"}, map[string]any{"kid": "k"}, ""},
+		"unknown crit":     {map[string]any{"alg": "EdDSA", "crit": []string{"exp"}, "exp": 1}, nil, "not understood"},
+		"crit not present": {map[string]any{"alg": "EdDSA", "crit": []string{"b64"}}, nil, "missing"},
+		"empty crit":       {map[string]any{"alg": "EdDSA", "crit": []string{}}, nil, "non-empty"},
+		"crit unprotected": {map[string]any{"alg": "EdDSA"}, map[string]any{"crit": []string{"b64"}}, "must be protected"},
+		"b64 without crit": {map[string]any{"alg": "EdDSA", "b64": false}, nil, `listed in "crit"`},
+		"duplicate name":   {map[string]any{"alg": "EdDSA", "kid": "a"}, map[string]any{"kid": "b"}, "both protected"},
+	} {
+		_, _, err := VerifyJSON(sign(tc.protected, tc.unprotected), nil, key.Public())
+		if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: got %v, want %q", name, err, tc.wantErr)
+		}
+	}
+
+	// A Verifier registers extensions and applies its alg policy and limits.
+	ext := sign(map[string]any{"alg": "EdDSA", "crit": []string{"exp"}, "exp": 1}, nil)
+	v := &Verifier{Algorithms: []string{"EdDSA"}, Critical: []string{"exp"}}
+	if hdr, _, err := v.VerifyJSON(ext, nil, key.Public()); err != nil || hdr["exp"] != float64(1) {
+		t.Errorf("registered crit: %v %v", hdr, err)
+	}
+	if _, _, err := (&Verifier{Algorithms: []string{"ES256"}, Critical: []string{"exp"}}).VerifyJSON(ext, nil, key.Public()); !errors.Is(err, ErrAlgNotAllowed) {
+		t.Errorf("disallowed alg: %v", err)
+	}
+	v.Limits = Limits{MaxTokenSize: 64}
+	if _, _, err := v.VerifyJSON(ext, nil, key.Public()); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("limits: %v", err)
+	}
+}
@@ -1,9 +1,11 @@
 package jwtverify
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/sha512"
@@ -23,40 +25,52 @@ import (
 //   - PS256/PS384/PS512 (RSA-PSS)
 //   - ES256/ES384/ES512 (ECDSA)
 //   - EdDSA (Ed25519)
+//   - HS256/HS384/HS512 (HMAC, key is a []byte secret)
 //
 // It returns the decoded header and payload JSON objects if the signature is valid.
+// Input is bounded by the default Limits, duplicate JSON members are rejected,
+// and "crit" may only list "b64" (RFC 7797); use a Verifier to change either.
 //
-// This snippet intentionally does NOT validate claims (exp/nbf/aud/etc.).
+// Verify does not validate claims (exp/nbf/aud/etc.); use a Validator.
 func Verify(token string, key any) (header map[string]any, payload map[string]any, err error) {
+	header, rawPayload, err := verifyCompact(token, key, parseOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, nil, fmt.Errorf("jwt: parse payload json: %w", err)
+	}
+	return header, payload, nil
+}
+
+// verifyCompact checks the signature of a compact JWS and returns its header
+// and the raw (decoded) claims, which must be a JSON object without
+// duplicate members.
+func verifyCompact(token string, key any, po parseOptions) (header map[string]any, rawPayload []byte, err error) {
+	if err := po.checkSize(len(token)); err != nil {
+		return nil, nil, err
+	}
 	p1, rest, ok := strings.Cut(token, ".")
 	if !ok {
 		return nil, nil, errors.New("jwt: expected 3 segments")
 	}
 	p2, p3, ok := strings.Cut(rest, ".")
-	if !ok {
+	if !ok || strings.Contains(p3, ".") {
 		return nil, nil, errors.New("jwt: expected 3 segments")
 	}
 
-	rawHdr, err := b64urlDecode(p1)
+	header, b64, err := jointHeader(p1, nil, po)
 	if err != nil {
-		return nil, nil, fmt.Errorf("jwt: decode header: %w", err)
+		return nil, nil, err
 	}
-	rawPayload, err := b64urlDecode(p2)
-	if err != nil {
-		return nil, nil, fmt.Errorf("jwt: decode payload: %w", err)
+	if rawPayload, _, err = payloadForSigning(&p2, nil, b64, po); err != nil {
+		return nil, nil, err
 	}
 	sig, err := b64urlDecode(p3)
 	if err != nil {
 		return nil, nil, fmt.Errorf("jwt: decode signature: %w", err)
 	}
 
-	if err := json.Unmarshal(rawHdr, &header); err != nil {
-		return nil, nil, fmt.Errorf("jwt: parse header json: %w", err)
-	}
-	if err := json.Unmarshal(rawPayload, &payload); err != nil {
-		return nil, nil, fmt.Errorf("jwt: parse payload json: %w", err)
-	}
-
 	alg, _ := header["alg"].(string)
 	if alg == "" {
 		return nil, nil, errors.New("jwt: missing alg")
@@ -69,7 +83,13 @@ func Verify(token string, key any) (header map[string]any, payload map[string]an
 	if err := verifyJWS(alg, signingInput, sig, key); err != nil {
 		return nil, nil, err
 	}
-	return header, payload, nil
+	if err := checkStrictJSON(rawPayload); err != nil {
+		return nil, nil, fmt.Errorf("jwt: parse payload json: %w", err)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(rawPayload), []byte("{")) {
+		return nil, nil, errors.New("jwt: payload is not a json object")
+	}
+	return header, rawPayload, nil
 }
 
 func verifyJWS(alg, signingInput string, sig []byte, key any) error {
@@ -115,6 +135,21 @@ func verifyJWS(alg, signingInput string, sig []byte, key any) error {
 			return errors.New("jwt: invalid signature")
 		}
 		return nil
+	case "HS256", "HS384", "HS512":
+		h, err := hashForAlg(alg)
+		if err != nil {
+			return err
+		}
+		secret, err := hmacSecret(key, h)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(h.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("jwt: invalid signature")
+		}
+		return nil
 	case "EdDSA":
 		pub, ok := key.(ed25519.PublicKey)
 		if !ok {
@@ -131,11 +166,11 @@ func verifyJWS(alg, signingInput string, sig []byte, key any) error {
 
 func hashForAlg(alg string) (crypto.Hash, error) {
 	switch alg {
-	case "RS256", "PS256", "ES256":
+	case "RS256", "PS256", "ES256", "HS256":
 		return crypto.SHA256, nil
-	case "RS384", "PS384", "ES384":
+	case "RS384", "PS384", "ES384", "HS384":
 		return crypto.SHA384, nil
-	case "RS512", "PS512", "ES512":
+	case "RS512", "PS512", "ES512", "HS512":
 		return crypto.SHA512, nil
 	default:
 		return 0, fmt.Errorf("jwt: no hash for alg %q", alg)
@@ -158,8 +193,13 @@ func digest(h crypto.Hash, msg []byte) []byte {
 	}
 }
 
+// b64urlDecode decodes unpadded base64url strictly, so each value has exactly
+// one accepted encoding.
 func b64urlDecode(s string) ([]byte, error) {
-	dec := base64.RawURLEncoding
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, errors.New("illegal newline in base64url data")
+	}
+	dec := base64.RawURLEncoding.Strict()
 	b, err := dec.DecodeString(s)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,284 @@
+package jwtverify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/net/httpclient"
+)
+
+// ErrKeyNotFound is returned when no key in a KeySet matches a token's kid
+// and alg.
+var ErrKeyNotFound = errors.New("jwt: no matching key")
+
+// KeySetOptions configures a KeySet.
+type KeySetOptions struct {
+	// Client fetches the JWKS. If nil, httpclient.New(httpclient.Options{MaxRetries: 2})
+	// is used.
+	Client *httpclient.Client
+	// MinRefreshInterval is the minimum time between two fetches, which rate
+	// limits refetches triggered by tokens with unknown kids. Default 1 minute.
+	MinRefreshInterval time.Duration
+	// DefaultTTL is how long keys are cached when the response has no
+	// Cache-Control max-age. Default 1 hour.
+	DefaultTTL time.Duration
+	// MaxTTL caps the max-age sent by the server. Default 24 hours.
+	MaxTTL time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+	// Verifier, if set, enforces its algorithm policy; keys whose JWK has an
+	// "alg" are bound to it.
+	Verifier *Verifier
+}
+
+// KeySet verifies tokens against the keys published at a JWKS URL.
+//
+// Keys are fetched lazily, cached for the response's Cache-Control max-age
+// and refetched (at most once per MinRefreshInterval) when a token names an
+// unknown kid, which picks up key rotation. If a refresh fails, the previous
+// keys keep being used.
+type KeySet struct {
+	url string
+	opt KeySetOptions
+
+	fetchMu sync.Mutex // serializes fetches
+
+	mu        sync.Mutex
+	keys      []JWK
+	lastFetch time.Time
+	expires   time.Time
+}
+
+// NewKeySet returns a KeySet for the JWKS at url.
+func NewKeySet(url string, opt KeySetOptions) *KeySet {
+	if opt.Client == nil {
+		opt.Client = httpclient.New(httpclient.Options{MaxRetries: 2})
+	}
+	if opt.MinRefreshInterval <= 0 {
+		opt.MinRefreshInterval = time.Minute
+	}
+	if opt.DefaultTTL <= 0 {
+		opt.DefaultTTL = time.Hour
+	}
+	if opt.MaxTTL <= 0 {
+		opt.MaxTTL = 24 * time.Hour
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	return &KeySet{url: url, opt: opt}
+}
+
+// Verify selects the key for token by its kid and alg header parameters and
+// verifies the signature with it (see Verify). Tokens without a kid are tried
+// against every key compatible with their alg.
+func (ks *KeySet) Verify(ctx context.Context, token string) (header, payload map[string]any, err error) {
+	header, rawPayload, err := ks.VerifyToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, nil, fmt.Errorf("jwt: parse payload json: %w", err)
+	}
+	return header, payload, nil
+}
+
+// VerifyToken is Verify returning the raw payload. It implements
+// TokenVerifier.
+func (ks *KeySet) VerifyToken(ctx context.Context, token string) (header map[string]any, payload []byte, err error) {
+	hdr, err := peekHeader(token, ks.opt.Verifier.parseOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	kid, _ := hdr["kid"].(string)
+	alg, _ := hdr["alg"].(string)
+
+	keys, err := ks.Lookup(ctx, kid, alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range keys {
+		if ks.opt.Verifier != nil {
+			header, payload, err = ks.opt.Verifier.verifyCompact(token, k)
+		} else {
+			header, payload, err = verifyCompact(token, k.Key, parseOptions{})
+		}
+		if err == nil {
+			return header, payload, nil
+		}
+	}
+	return nil, nil, err
+}
+
+// Lookup returns the keys matching kid (if non-empty) that can verify alg.
+// An unknown kid triggers a rate-limited refetch before giving up with
+// ErrKeyNotFound.
+func (ks *KeySet) Lookup(ctx context.Context, kid, alg string) ([]JWK, error) {
+	keys, err := ks.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m := matchKeys(keys, kid, alg); len(m) > 0 {
+		return m, nil
+	}
+	if kid != "" && ks.refreshAllowed() {
+		if err := ks.refresh(ctx, false); err != nil {
+			return nil, err
+		}
+		keys, _ = ks.cached()
+		if m := matchKeys(keys, kid, alg); len(m) > 0 {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q alg %q", ErrKeyNotFound, kid, alg)
+}
+
+// Keys returns the cached keys, fetching them first if the cache is empty or
+// expired.
+func (ks *KeySet) Keys(ctx context.Context) ([]JWK, error) {
+	keys, fresh := ks.cached()
+	if fresh {
+		return keys, nil
+	}
+	if err := ks.refresh(ctx, false); err != nil {
+		if keys != nil {
+			return keys, nil // serve stale keys while the IdP is unavailable
+		}
+		return nil, err
+	}
+	keys, _ = ks.cached()
+	return keys, nil
+}
+
+// Refresh fetches the JWKS now, ignoring the cache and MinRefreshInterval.
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	return ks.refresh(ctx, true)
+}
+
+func (ks *KeySet) cached() ([]JWK, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.keys, ks.keys != nil && ks.opt.Now().Before(ks.expires)
+}
+
+func (ks *KeySet) refreshAllowed() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.lastFetch.IsZero() || ks.opt.Now().Sub(ks.lastFetch) >= ks.opt.MinRefreshInterval
+}
+
+func (ks *KeySet) refresh(ctx context.Context, force bool) error {
+	start := ks.opt.Now()
+	ks.fetchMu.Lock()
+	defer ks.fetchMu.Unlock()
+
+	// Another goroutine may have fetched while we waited.
+	ks.mu.Lock()
+	done := !force && ks.lastFetch.After(start)
+	ks.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	keys, ttl, err := ks.fetch(ctx)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	now := ks.opt.Now()
+	ks.lastFetch = now
+	if err != nil {
+		// Back off before the next attempt; stale keys remain usable.
+		ks.expires = now.Add(ks.opt.MinRefreshInterval)
+		return err
+	}
+	ks.keys = keys
+	ks.expires = now.Add(ttl)
+	return nil
+}
+
+func (ks *KeySet) fetch(ctx context.Context) ([]JWK, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("jwt: jwks request: %w", err)
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+	resp, err := ks.opt.Client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("jwt: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("jwt: fetch jwks: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, fmt.Errorf("jwt: fetch jwks: %w", err)
+	}
+	keys, err := ParseJWKS(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return keys, ks.ttl(resp.Header), nil
+}
+
+// ttl derives the cache lifetime from Cache-Control, clamped to
+// [MinRefreshInterval, MaxTTL].
+func (ks *KeySet) ttl(h http.Header) time.Duration {
+	ttl := ks.opt.DefaultTTL
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache":
+				ttl = 0
+			case "max-age":
+				if n, err := strconv.ParseInt(strings.Trim(val, `"`), 10, 64); err == nil && n >= 0 {
+					ttl = time.Duration(n) * time.Second
+				}
+			}
+		}
+	}
+	return min(max(ttl, ks.opt.MinRefreshInterval), ks.opt.MaxTTL)
+}
+
+// matchKeys returns the signing keys with the given kid (any kid if empty)
+// whose declared alg and key type are compatible with alg.
+func matchKeys(keys []JWK, kid, alg string) []JWK {
+	var out []JWK
+	for _, k := range keys {
+		if kid != "" && k.KeyID != kid {
+			continue
+		}
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if k.Algorithm != "" && k.Algorithm != alg {
+			continue
+		}
+		if !keyFitsAlg(k.Key, alg) {
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// peekHeader decodes a compact token's header without verifying it.
+func peekHeader(token string, po parseOptions) (map[string]any, error) {
+	if err := po.checkSize(len(token)); err != nil {
+		return nil, err
+	}
+	p1, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("jwt: expected 3 segments")
+	}
+	return po.decodeHeader(p1)
+}
@@ -0,0 +1,228 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// jwkJSON renders the public part of key as a JWK member map.
+func jwkJSON(kid string, key any) map[string]any {
+	m := map[string]any{"kid": kid}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		m["kty"], m["n"], m["e"] = "RSA", b64url(k.N.Bytes()), b64url(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		m["kty"], m["crv"] = "EC", k.Curve.Params().Name
+		m["x"], m["y"] = b64url(k.X.FillBytes(make([]byte, size))), b64url(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		m["kty"], m["crv"], m["x"] = "OKP", "Ed25519", b64url(k)
+	}
+	return m
+}
+
+// signTestToken produces a compact JWS for RS256, ES256 or EdDSA.
+func signTestToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	hdr := map[string]any{"alg": alg}
+	if kid != "" {
+		hdr["kid"] = kid
+	}
+	input := b64url(mustJSON(t, hdr)) + "." + b64url(mustJSON(t, claims))
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = key.Sign(rand.Reader, digest(crypto.SHA256, []byte(input)), crypto.SHA256)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest(crypto.SHA256, []byte(input)))
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "EdDSA":
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(input))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64url(sig)
+}
+
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []map[string]any
+	cc      string
+	fail    bool
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if s.cc != "" {
+			w.Header().Set("Cache-Control", s.cc)
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(mustJSON(t, map[string]any{"keys": s.keys}))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(cc string, fail bool, keys ...map[string]any) {
+	s.mu.Lock()
+	s.keys, s.cc, s.fail = keys, cc, fail
+	s.mu.Unlock()
+}
+
+func TestKeySet_SelectsByKid(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	srv := newJWKSServer(t)
+	srv.set("", false, jwkJSON("rsa-1", &rsaKey.PublicKey), jwkJSON("ec-1", &ecKey.PublicKey), jwkJSON("ed-1", edPub))
+	ks := NewKeySet(srv.URL, KeySetOptions{})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		alg, kid string
+		key      crypto.Signer
+	}{
+		{"RS256", "rsa-1", rsaKey},
+		{"ES256", "ec-1", ecKey},
+		{"EdDSA", "ed-1", edKey},
+		{"EdDSA", "", edKey}, // no kid: tried against compatible keys
+	} {
+		tok := signTestToken(t, tc.alg, tc.kid, tc.key, map[string]any{"sub": tc.kid})
+		if _, p, err := ks.Verify(ctx, tok); err != nil || p["sub"] != tc.kid {
+			t.Fatalf("%s/%s: payload %v, err %v", tc.alg, tc.kid, p, err)
+		}
+	}
+	// A kid pointing at a key of the wrong type must not match.
+	tok := signTestToken(t, "RS256", "ec-1", rsaKey, map[string]any{})
+	if _, _, err := ks.Verify(ctx, tok); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+}
+
+func TestKeySet_RotationIsRateLimited(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	clock := &fakeClock{t: time.Now()}
+	srv := newJWKSServer(t)
+	srv.set("", false, jwkJSON("old", oldKey.Public()))
+	ks := NewKeySet(srv.URL, KeySetOptions{MinRefreshInterval: time.Minute, Now: clock.Now})
+	ctx := context.Background()
+
+	tok := signTestToken(t, "EdDSA", "new", newKey, map[string]any{})
+	if _, _, err := ks.Verify(ctx, tok); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	srv.set("", false, jwkJSON("old", oldKey.Public()), jwkJSON("new", newKey.Public()))
+	if _, _, err := ks.Verify(ctx, tok); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("refetch was not rate limited: %v", err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, _, err := ks.Verify(ctx, tok); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("fetched %d times, want 2", n)
+	}
+}
+
+func TestKeySet_CacheControlAndStale(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	clock := &fakeClock{t: time.Now()}
+	srv := newJWKSServer(t)
+	srv.set("public, max-age=300", false, jwkJSON("k", key.Public()))
+	ks := NewKeySet(srv.URL, KeySetOptions{Now: clock.Now})
+	ctx := context.Background()
+	tok := signTestToken(t, "EdDSA", "k", key, map[string]any{})
+
+	if _, _, err := ks.Verify(ctx, tok); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(4 * time.Minute)
+	ks.Verify(ctx, tok)
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times within max-age", n)
+	}
+
+	// Expired and the server is failing: stale keys are still used.
+	srv.set("", true)
+	clock.Advance(2 * time.Minute)
+	if _, _, err := ks.Verify(ctx, tok); err != nil {
+		t.Fatalf("stale keys not used: %v", err)
+	}
+	if n := srv.fetches.Load(); n < 2 {
+		t.Fatalf("expired cache was not refetched")
+	}
+}
+
+func TestParseJWKS_SkipsUnsupported(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bad := jwkJSON("bad", &ecKey.PublicKey)
+	bad["y"] = bad["x"] // not on the curve
+	data := mustJSON(t, map[string]any{"keys": []map[string]any{
+		{"kty": "oct", "k": "c2VjcmV0"},
+		{"kty": "OKP", "crv": "X25519", "x": b64url(make([]byte, 32))},
+		bad,
+		jwkJSON("good", &ecKey.PublicKey),
+	}})
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].KeyID != "good" || !ecKey.PublicKey.Equal(keys[0].Key) {
+		t.Fatalf("keys = %+v", keys)
+	}
+}
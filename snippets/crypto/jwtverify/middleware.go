@@ -0,0 +1,225 @@
+package jwtverify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// TokenVerifier verifies a compact JWS and returns its header and raw
+// payload. *KeySet and StaticKey implement it.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (header map[string]any, payload []byte, err error)
+}
+
+// StaticKey is a TokenVerifier for a single key. Key may be a BoundKey or a
+// JWK. If Verifier is set, its algorithm policy applies.
+type StaticKey struct {
+	Key      any
+	Verifier *Verifier
+}
+
+// VerifyToken implements TokenVerifier.
+func (s StaticKey) VerifyToken(_ context.Context, token string) (map[string]any, []byte, error) {
+	if s.Verifier != nil {
+		return s.Verifier.verifyCompact(token, s.Key)
+	}
+	return verifyCompact(token, s.Key, parseOptions{})
+}
+
+// AuthOptions configures Middleware.
+type AuthOptions struct {
+	// Keys verifies token signatures. Required.
+	Keys TokenVerifier
+	// Validator validates the claims. If nil, a zero Validator is used, which
+	// still rejects expired and not yet valid tokens.
+	Validator *Validator
+	// Scopes lists scopes that must all be granted, read from the "scope"
+	// claim (space-separated) or "scp" (string or array).
+	Scopes []string
+	// Realm is reported in WWW-Authenticate.
+	Realm string
+	// Cookie, if set, is the name of a cookie to read the token from when
+	// there is no Authorization header.
+	Cookie string
+	// QueryParam, if set, accepts the token in this query parameter (RFC 6750
+	// section 2.3 uses "access_token"). Prefer headers: URLs end up in logs.
+	QueryParam string
+	// Replay, if set, rejects tokens that were already accepted. Use it for
+	// one-time tokens; replayed and store failures both answer 401.
+	Replay *ReplayDetector
+	// OnError, if set, is called with the reason a request was rejected,
+	// e.g. for logging. Clients only see a generic description.
+	OnError func(r *http.Request, err error)
+}
+
+type claimsKey[T any] struct{}
+
+// ClaimsFromContext returns the claims stored by Middleware[T].
+func ClaimsFromContext[T any](ctx context.Context) (T, bool) {
+	c, ok := ctx.Value(claimsKey[T]{}).(T)
+	return c, ok
+}
+
+// Middleware returns bearer-token authentication middleware. Verified claims
+// are decoded into a T (see VerifyInto) and stored in the request context;
+// read them with ClaimsFromContext[T].
+//
+// Failures are answered per RFC 6750 section 3: 401 with
+// WWW-Authenticate: Bearer (and error="invalid_token" if a token was sent),
+// 403 with error="insufficient_scope" for missing scopes, and 400 with
+// error="invalid_request" when a token is sent in more than one way.
+func Middleware[T any](opt AuthOptions) func(http.Handler) http.Handler {
+	if opt.Keys == nil {
+		panic("jwtverify: AuthOptions.Keys is nil")
+	}
+	if opt.Validator == nil {
+		opt.Validator = &Validator{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := extractToken(r, opt)
+			if err != nil {
+				opt.reject(w, r, http.StatusBadRequest, "invalid_request", err)
+				return
+			}
+			if token == "" {
+				opt.reject(w, r, http.StatusUnauthorized, "", errors.New("jwt: no bearer token"))
+				return
+			}
+
+			_, raw, err := opt.Keys.VerifyToken(r.Context(), token)
+			if err != nil {
+				opt.reject(w, r, http.StatusUnauthorized, "invalid_token", err)
+				return
+			}
+			var claims map[string]any
+			if err := decodeNumbers(raw, &claims); err != nil {
+				opt.reject(w, r, http.StatusUnauthorized, "invalid_token", err)
+				return
+			}
+			if err := opt.Validator.Validate(claims); err != nil {
+				opt.reject(w, r, http.StatusUnauthorized, "invalid_token", err)
+				return
+			}
+			if missing := missingScopes(claims, opt.Scopes); len(missing) > 0 {
+				opt.reject(w, r, http.StatusForbidden, "insufficient_scope", fmt.Errorf("jwt: missing scopes %q", missing))
+				return
+			}
+			var typed T
+			if err := decodeNumbers(raw, &typed); err != nil {
+				opt.reject(w, r, http.StatusUnauthorized, "invalid_token", err)
+				return
+			}
+			// Last, so a token rejected for any other reason is not used up.
+			if opt.Replay != nil {
+				if err := opt.Replay.Check(r.Context(), token, claims); err != nil {
+					opt.reject(w, r, http.StatusUnauthorized, "invalid_token", err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey[T]{}, typed)))
+		})
+	}
+}
+
+func extractToken(r *http.Request, opt AuthOptions) (string, error) {
+	var token string
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, t, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(t)
+		}
+	}
+	if opt.QueryParam != "" {
+		if q := r.URL.Query().Get(opt.QueryParam); q != "" {
+			if token != "" {
+				return "", errors.New("jwt: token sent in both header and query")
+			}
+			token = q
+		}
+	}
+	if token == "" && opt.Cookie != "" {
+		if c, err := r.Cookie(opt.Cookie); err == nil {
+			token = c.Value
+		}
+	}
+	return token, nil
+}
+
+// reject writes an RFC 6750 error response.
+func (opt AuthOptions) reject(w http.ResponseWriter, r *http.Request, status int, code string, err error) {
+	if opt.OnError != nil {
+		opt.OnError(r, err)
+	}
+	params := []string{"Bearer"}
+	add := func(name, value string) {
+		sep := " "
+		if len(params) > 1 {
+			sep = ", "
+		}
+		params = append(params, sep+name+`="`+quoteEscaper.Replace(value)+`"`)
+	}
+	if opt.Realm != "" {
+		add("realm", opt.Realm)
+	}
+	if code != "" {
+		add("error", code)
+		add("error_description", describe(code, err))
+	}
+	if code == "insufficient_scope" {
+		add("scope", strings.Join(opt.Scopes, " "))
+	}
+	w.Header().Set("WWW-Authenticate", strings.Join(params, ""))
+	http.Error(w, http.StatusText(status), status)
+}
+
+// quoteEscaper escapes a WWW-Authenticate quoted-string (RFC 9110 section
+// 5.6.4).
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// describe returns a client-safe error_description.
+func describe(code string, err error) string {
+	switch {
+	case code == "invalid_request":
+		return "The token was sent in more than one way"
+	case code == "insufficient_scope":
+		return "The token lacks a required scope"
+	case errors.Is(err, ErrTokenExpired):
+		return "The token expired"
+	case errors.Is(err, ErrReplay):
+		return "The token was already used"
+	case errors.Is(err, ErrTokenNotYetValid), errors.Is(err, ErrTokenIssuedFuture):
+		return "The token is not valid yet"
+	default:
+		return "The token is invalid"
+	}
+}
+
+// missingScopes returns the required scopes not granted by claims.
+func missingScopes(claims map[string]any, required []string) []string {
+	var granted []string
+	if s, ok := claims["scope"].(string); ok {
+		granted = strings.Fields(s)
+	}
+	switch scp := claims["scp"].(type) {
+	case string:
+		granted = append(granted, strings.Fields(scp)...)
+	case []any:
+		for _, v := range scp {
+			if s, ok := v.(string); ok {
+				granted = append(granted, s)
+			}
+		}
+	}
+	var missing []string
+	for _, s := range required {
+		if !slices.Contains(granted, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,112 @@
+package jwtverify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type apiClaims struct {
+	RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+func TestMiddleware(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	sign := func(claims map[string]any) string {
+		tok, err := Sign(nil, claims, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	h := Middleware[apiClaims](AuthOptions{
+		Keys:       StaticKey{Key: pub, Verifier: &Verifier{Algorithms: []string{"EdDSA"}}},
+		Validator:  &Validator{Audiences: []string{"api"}},
+		Scopes:     []string{"read"},
+		Realm:      "example",
+		Cookie:     "session",
+		QueryParam: "access_token",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ClaimsFromContext[apiClaims](r.Context())
+		if !ok {
+			t.Error("claims missing from context")
+		}
+		w.Write([]byte(c.Subject))
+	}))
+
+	good := sign(map[string]any{"sub": "alice", "aud": "api", "exp": exp, "scope": "read write"})
+	for _, tc := range []struct {
+		name      string
+		req       func(*http.Request)
+		status    int
+		challenge string
+	}{
+		{"header", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+good) }, 200, ""},
+		{"lowercase scheme", func(r *http.Request) { r.Header.Set("Authorization", "bearer "+good) }, 200, ""},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: good}) }, 200, ""},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "access_token=" + good }, 200, ""},
+		{"missing", func(r *http.Request) {}, 401, `Bearer realm="example"`},
+		{"garbage", func(r *http.Request) { r.Header.Set("Authorization", "Bearer x.y.z") }, 401, `error="invalid_token"`},
+		{"expired", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(map[string]any{"aud": "api", "exp": time.Now().Add(-time.Hour).Unix(), "scope": "read"}))
+		}, 401, `error_description="The token expired"`},
+		{"wrong audience", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(map[string]any{"aud": "other", "exp": exp, "scope": "read"}))
+		}, 401, `error="invalid_token"`},
+		{"scope", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(map[string]any{"aud": "api", "exp": exp, "scp": []string{"write"}}))
+		}, 403, `error="insufficient_scope", error_description="The token lacks a required scope", scope="read"`},
+		{"two methods", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+good)
+			r.URL.RawQuery = "access_token=" + good
+		}, 400, `error="invalid_request"`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		tc.req(req)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, tc.challenge) || (tc.status != 200) != (got != "") {
+			t.Errorf("%s: WWW-Authenticate = %q, want it to contain %q", tc.name, got, tc.challenge)
+		}
+		if tc.status == 200 && rec.Body.String() != "alice" {
+			t.Errorf("%s: body %q", tc.name, rec.Body.String())
+		}
+	}
+
+	// Realm and scope are quoted-strings; quotes and backslashes are escaped.
+	quoted := Middleware[apiClaims](AuthOptions{Keys: StaticKey{Key: pub}, Realm: `a"b\c`, Scopes: []string{`x"y`}})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(map[string]any{"exp": exp}))
+	rec := httptest.NewRecorder()
+	quoted.ServeHTTP(rec, req)
+	if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, `realm="a\"b\\c"`) || !strings.Contains(got, `scope="x\"y"`) {
+		t.Errorf("quoting: WWW-Authenticate = %q", got)
+	}
+
+	// Without a Validator, time claims are still enforced.
+	noValidator := Middleware[apiClaims](AuthOptions{Keys: StaticKey{Key: pub}})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for name, claims := range map[string]map[string]any{
+		"expired, no validator":       {"exp": time.Now().Add(-time.Hour).Unix()},
+		"not yet valid, no validator": {"nbf": time.Now().Add(time.Hour).Unix()},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+sign(claims))
+		rec := httptest.NewRecorder()
+		noValidator.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, rec.Code)
+		}
+	}
+}
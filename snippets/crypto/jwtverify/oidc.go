@@ -0,0 +1,262 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/net/httpclient"
+)
+
+// OpenID Connect validation errors.
+var (
+	ErrNonceMismatch     = errors.New("jwt: nonce mismatch")
+	ErrAuthTooOld        = errors.New("jwt: authentication too old")
+	ErrTokenHashMismatch = errors.New("jwt: token hash mismatch")
+)
+
+// ProviderMetadata is the part of the OpenID Provider Metadata (OpenID
+// Connect Discovery 1.0 section 3) used here.
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// OIDCOptions configures a Provider.
+type OIDCOptions struct {
+	// ClientID is this relying party's client_id. Required.
+	ClientID string
+	// TrustedAudiences lists other audiences an ID token may name besides
+	// ClientID. Tokens with any other audience are rejected.
+	TrustedAudiences []string
+	// Algorithms pins the ID token signing algorithms. Defaults to the
+	// provider's id_token_signing_alg_values_supported (asymmetric ones
+	// only), or RS256 if it lists none.
+	Algorithms []string
+	// Leeway is the allowed clock skew for exp, iat and auth_time.
+	Leeway time.Duration
+	// Client fetches the discovery document and JWKS. If nil,
+	// httpclient.New(httpclient.Options{MaxRetries: 2}) is used.
+	Client *httpclient.Client
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Provider validates ID tokens from one OpenID Provider.
+type Provider struct {
+	meta ProviderMetadata
+	opt  OIDCOptions
+	keys *KeySet
+}
+
+// NewProvider loads issuer's /.well-known/openid-configuration. The issuer in
+// the document must match issuer exactly.
+func NewProvider(ctx context.Context, issuer string, opt OIDCOptions) (*Provider, error) {
+	if opt.ClientID == "" {
+		return nil, errors.New("jwt: oidc client id is required")
+	}
+	if opt.Client == nil {
+		opt.Client = httpclient.New(httpclient.Options{MaxRetries: 2})
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	var meta ProviderMetadata
+	if err := getJSON(ctx, opt.Client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("jwt: oidc discovery: %w", err)
+	}
+	if meta.Issuer != issuer {
+		return nil, fmt.Errorf("jwt: oidc discovery: issuer %q does not match %q", meta.Issuer, issuer)
+	}
+	if meta.JWKSURI == "" {
+		return nil, errors.New("jwt: oidc discovery: missing jwks_uri")
+	}
+	if len(opt.Algorithms) == 0 {
+		for _, alg := range meta.IDTokenSigningAlgValuesSupported {
+			if alg != "none" && !strings.HasPrefix(alg, "HS") {
+				opt.Algorithms = append(opt.Algorithms, alg)
+			}
+		}
+		if len(opt.Algorithms) == 0 {
+			opt.Algorithms = []string{"RS256"}
+		}
+	}
+	keys := NewKeySet(meta.JWKSURI, KeySetOptions{
+		Client:   opt.Client,
+		Now:      opt.Now,
+		Verifier: &Verifier{Algorithms: opt.Algorithms},
+	})
+	return &Provider{meta: meta, opt: opt, keys: keys}, nil
+}
+
+// Metadata returns the discovered provider metadata.
+func (p *Provider) Metadata() ProviderMetadata { return p.meta }
+
+// IDTokenOptions are the per-login checks for VerifyIDToken.
+type IDTokenOptions struct {
+	// Nonce, if set, must equal the token's "nonce". Always set it when the
+	// authentication request carried one.
+	Nonce string
+	// MaxAge, if positive, requires "auth_time" to be no older than this (the
+	// max_age request parameter).
+	MaxAge time.Duration
+	// RequireAuthTime requires "auth_time" even without MaxAge.
+	RequireAuthTime bool
+	// AccessToken, if set, is checked against "at_hash" when the token has
+	// one (it is optional in the code flow).
+	AccessToken string
+	// Code, if set, must match "c_hash", which is then required (hybrid
+	// flow).
+	Code string
+}
+
+// IDToken is a validated ID token.
+type IDToken struct {
+	RegisteredClaims
+	AuthorizedParty string       `json:"azp,omitempty"`
+	Nonce           string       `json:"nonce,omitempty"`
+	AuthTime        *NumericDate `json:"auth_time,omitempty"`
+	AccessTokenHash string       `json:"at_hash,omitempty"`
+	CodeHash        string       `json:"c_hash,omitempty"`
+	ACR             string       `json:"acr,omitempty"`
+	AMR             []string     `json:"amr,omitempty"`
+
+	// Header is the JWS header.
+	Header map[string]any `json:"-"`
+	// Claims holds all claims, for those not covered above.
+	Claims map[string]any `json:"-"`
+}
+
+// VerifyIDToken verifies an ID token's signature against the provider's JWKS
+// and validates it per OpenID Connect Core 1.0 section 3.1.3.7: iss, aud and
+// azp, exp and iat, plus nonce, auth_time, at_hash and c_hash as configured
+// by check.
+func (p *Provider) VerifyIDToken(ctx context.Context, token string, check IDTokenOptions) (*IDToken, error) {
+	header, raw, err := p.keys.VerifyToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := decodeNumbers(raw, &claims); err != nil {
+		return nil, err
+	}
+	v := Validator{
+		Issuers:   []string{p.meta.Issuer},
+		Audiences: []string{p.opt.ClientID},
+		Required:  []string{"sub", "exp", "iat"},
+		Leeway:    p.opt.Leeway,
+		Now:       p.opt.Now,
+	}
+	if err := v.Validate(claims); err != nil {
+		return nil, err
+	}
+	var tok IDToken
+	if err := decodeNumbers(raw, &tok); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClaim, err)
+	}
+	tok.Header, tok.Claims = header, claims
+
+	for _, aud := range tok.Audience {
+		if aud != p.opt.ClientID && !slices.Contains(p.opt.TrustedAudiences, aud) {
+			return nil, fmt.Errorf("%w: untrusted audience %q", ErrAudienceMismatch, aud)
+		}
+	}
+	if tok.AuthorizedParty != "" && tok.AuthorizedParty != p.opt.ClientID {
+		return nil, fmt.Errorf("%w: azp %q", ErrAudienceMismatch, tok.AuthorizedParty)
+	}
+	if len(tok.Audience) > 1 && tok.AuthorizedParty == "" {
+		return nil, fmt.Errorf("%w: %q", ErrMissingClaim, "azp")
+	}
+
+	if check.Nonce != "" && subtle.ConstantTimeCompare([]byte(tok.Nonce), []byte(check.Nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+	if tok.AuthTime == nil && (check.MaxAge > 0 || check.RequireAuthTime) {
+		return nil, fmt.Errorf("%w: %q", ErrMissingClaim, "auth_time")
+	}
+	if tok.AuthTime != nil {
+		age := p.opt.Now().Sub(tok.AuthTime.Time)
+		if age < -p.opt.Leeway {
+			return nil, fmt.Errorf("%w: %q is in the future", ErrInvalidClaim, "auth_time")
+		}
+		if check.MaxAge > 0 && age > check.MaxAge+p.opt.Leeway {
+			return nil, fmt.Errorf("%w: authenticated %s ago", ErrAuthTooOld, age.Round(time.Second))
+		}
+	}
+
+	alg, _ := header["alg"].(string)
+	if check.AccessToken != "" && tok.AccessTokenHash != "" {
+		if err := checkTokenHash(alg, check.AccessToken, tok.AccessTokenHash); err != nil {
+			return nil, fmt.Errorf("%w: at_hash", err)
+		}
+	}
+	if check.Code != "" {
+		if tok.CodeHash == "" {
+			return nil, fmt.Errorf("%w: %q", ErrMissingClaim, "c_hash")
+		}
+		if err := checkTokenHash(alg, check.Code, tok.CodeHash); err != nil {
+			return nil, fmt.Errorf("%w: c_hash", err)
+		}
+	}
+	return &tok, nil
+}
+
+// TokenHash computes an at_hash or c_hash value: the base64url-encoded left
+// half of the hash of value, using the hash of the ID token's alg (SHA-512
+// for EdDSA with Ed25519).
+func TokenHash(alg, value string) (string, error) {
+	h := crypto.SHA512
+	if alg != "EdDSA" {
+		var err error
+		if h, err = hashForAlg(alg); err != nil {
+			return "", err
+		}
+	}
+	sum := digest(h, []byte(value))
+	return b64urlEncode(sum[:len(sum)/2]), nil
+}
+
+func checkTokenHash(alg, value, want string) error {
+	got, err := TokenHash(alg, value)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return ErrTokenHashMismatch
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, c *httpclient.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,157 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testOP is a minimal OpenID Provider that mints ID tokens.
+type testOP struct {
+	*httptest.Server
+	key    *ecdsa.PrivateKey
+	issuer string // as published; defaults to the server URL
+}
+
+func newTestOP(t *testing.T) *testOP {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	op := &testOP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(mustJSON(t, map[string]any{
+			"issuer":                                op.issuer,
+			"jwks_uri":                              op.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"ES256", "HS256"},
+		}))
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(mustJSON(t, map[string]any{"keys": []any{jwkJSON("op-1", &key.PublicKey)}}))
+	})
+	op.Server = httptest.NewServer(mux)
+	op.issuer = op.URL
+	t.Cleanup(op.Close)
+	return op
+}
+
+// mint signs an ID token for client "app" with the given claims overriding
+// the defaults; a nil value deletes a claim.
+func (op *testOP) mint(t *testing.T, over map[string]any) string {
+	now := time.Now()
+	claims := map[string]any{
+		"iss": op.issuer, "sub": "alice", "aud": "app",
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+		"auth_time": now.Add(-time.Minute).Unix(), "nonce": "n-0S6_WzA2Mj",
+	}
+	maps.Copy(claims, over)
+	maps.DeleteFunc(claims, func(_ string, v any) bool { return v == nil })
+	return signTestToken(t, "ES256", "op-1", op.key, claims)
+}
+
+func TestNewProvider_Discovery(t *testing.T) {
+	op := newTestOP(t)
+	ctx := context.Background()
+	p, err := NewProvider(ctx, op.URL, OIDCOptions{ClientID: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Metadata().JWKSURI; got != op.URL+"/jwks" {
+		t.Fatalf("jwks_uri = %q", got)
+	}
+	if _, err := NewProvider(ctx, op.URL+"/", OIDCOptions{ClientID: "app"}); err == nil {
+		t.Fatal("issuer with trailing slash accepted")
+	}
+	op.issuer = "https://evil.example"
+	if _, err := NewProvider(ctx, op.URL, OIDCOptions{ClientID: "app"}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("mismatched issuer: %v", err)
+	}
+	if _, err := NewProvider(ctx, op.URL, OIDCOptions{}); err == nil {
+		t.Fatal("missing client id accepted")
+	}
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	op := newTestOP(t)
+	ctx := context.Background()
+	p, err := NewProvider(ctx, op.URL, OIDCOptions{ClientID: "app", TrustedAudiences: []string{"api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	atHash, _ := TokenHash("ES256", "access-token")
+	cHash, _ := TokenHash("ES256", "the-code")
+
+	tok, err := p.VerifyIDToken(ctx, op.mint(t, map[string]any{"at_hash": atHash, "amr": []string{"pwd"}}),
+		IDTokenOptions{Nonce: "n-0S6_WzA2Mj", MaxAge: 5 * time.Minute, AccessToken: "access-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Subject != "alice" || tok.AuthTime == nil || tok.AMR[0] != "pwd" || tok.Header["kid"] != "op-1" || tok.Claims["sub"] != "alice" {
+		t.Fatalf("token = %+v", tok)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]any
+		check  IDTokenOptions
+		want   error
+	}{
+		{"wrong issuer", map[string]any{"iss": "https://other"}, IDTokenOptions{}, ErrIssuerMismatch},
+		{"wrong audience", map[string]any{"aud": "other-app"}, IDTokenOptions{}, ErrAudienceMismatch},
+		{"untrusted extra audience", map[string]any{"aud": []string{"app", "evil"}, "azp": "app"}, IDTokenOptions{}, ErrAudienceMismatch},
+		{"multiple audiences without azp", map[string]any{"aud": []string{"app", "api"}}, IDTokenOptions{}, ErrMissingClaim},
+		{"azp mismatch", map[string]any{"azp": "api"}, IDTokenOptions{}, ErrAudienceMismatch},
+		{"expired", map[string]any{"exp": now.Add(-time.Minute).Unix()}, IDTokenOptions{}, ErrTokenExpired},
+		{"missing iat", map[string]any{"iat": nil}, IDTokenOptions{}, ErrMissingClaim},
+		{"missing sub", map[string]any{"sub": nil}, IDTokenOptions{}, ErrMissingClaim},
+		{"wrong nonce", nil, IDTokenOptions{Nonce: "other"}, ErrNonceMismatch},
+		{"missing nonce", map[string]any{"nonce": nil}, IDTokenOptions{Nonce: "n-0S6_WzA2Mj"}, ErrNonceMismatch},
+		{"auth too old", map[string]any{"auth_time": now.Add(-time.Hour).Unix()}, IDTokenOptions{MaxAge: 10 * time.Minute}, ErrAuthTooOld},
+		{"missing auth_time", map[string]any{"auth_time": nil}, IDTokenOptions{RequireAuthTime: true}, ErrMissingClaim},
+		{"future auth_time", map[string]any{"auth_time": now.Add(time.Hour).Unix()}, IDTokenOptions{}, ErrInvalidClaim},
+		{"future auth_time with max_age", map[string]any{"auth_time": now.Add(time.Hour).Unix()}, IDTokenOptions{MaxAge: 10 * time.Minute}, ErrInvalidClaim},
+		{"bad at_hash", map[string]any{"at_hash": atHash}, IDTokenOptions{AccessToken: "stolen"}, ErrTokenHashMismatch},
+		{"missing c_hash", nil, IDTokenOptions{Code: "the-code"}, ErrMissingClaim},
+		{"bad c_hash", map[string]any{"c_hash": cHash}, IDTokenOptions{Code: "other-code"}, ErrTokenHashMismatch},
+	} {
+		_, err := p.VerifyIDToken(ctx, op.mint(t, tc.claims), tc.check)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if _, err := p.VerifyIDToken(ctx, op.mint(t, map[string]any{"c_hash": cHash, "azp": "app", "aud": []string{"app", "api"}}), IDTokenOptions{Code: "the-code"}); err != nil {
+		t.Errorf("hybrid flow: %v", err)
+	}
+
+	// HS256 is advertised but never trusted, and foreign keys do not verify.
+	hs, _ := Sign(map[string]any{"alg": "HS256", "kid": "op-1"}, map[string]any{"iss": op.URL, "aud": "app"}, make([]byte, 32))
+	if _, err := p.VerifyIDToken(ctx, hs, IDTokenOptions{}); err == nil {
+		t.Error("HS256 token accepted")
+	}
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	forged := signTestToken(t, "EdDSA", "op-1", other, map[string]any{"iss": op.URL, "sub": "alice", "aud": "app"})
+	if _, err := p.VerifyIDToken(ctx, forged, IDTokenOptions{}); err == nil {
+		t.Error("forged token accepted")
+	}
+}
+
+func TestTokenHash_OIDCExamples(t *testing.T) {
+	// OpenID Connect Core 1.0 appendix A.3 and A.4 (RS256).
+	for value, want := range map[string]string{
+		"jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y":                "77QmUPtjPfzWtF2AnpK9RQ",
+		"Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk": "LDktKdoQak3Pk0cnXxCltA",
+	} {
+		if got, err := TokenHash("RS256", value); err != nil || got != want {
+			t.Errorf("TokenHash(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+}
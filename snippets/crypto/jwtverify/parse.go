@@ -0,0 +1,216 @@
+package jwtverify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Parsing errors.
+var (
+	ErrTokenTooLarge = errors.New("jwt: token exceeds size limit")
+	ErrDuplicateKey  = errors.New("jwt: duplicate json member")
+	ErrCritical      = errors.New("jwt: critical header not understood")
+)
+
+// Limits bounds the work done on untrusted input before any signature is
+// checked. A zero field uses the default.
+type Limits struct {
+	// MaxTokenSize caps the encoded token (or JWS JSON document). Default
+	// 64 KiB.
+	MaxTokenSize int
+	// MaxHeaderSize caps the decoded (protected) header. Default 8 KiB.
+	MaxHeaderSize int
+	// MaxPayloadSize caps the decoded payload or JWE ciphertext. Default
+	// 48 KiB. Detached payloads supplied by the caller are not limited.
+	MaxPayloadSize int
+}
+
+func (l Limits) token() int   { return cmpOr(l.MaxTokenSize, 64<<10) }
+func (l Limits) header() int  { return cmpOr(l.MaxHeaderSize, 8<<10) }
+func (l Limits) payload() int { return cmpOr(l.MaxPayloadSize, 48<<10) }
+
+func cmpOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// maxJSONDepth bounds nesting in headers and claims; real tokens are shallow.
+const maxJSONDepth = 32
+
+// understoodCrit is the registry of JWS "crit" extensions this package
+// implements itself. Callers add their own with Verifier.Critical.
+var understoodCrit = []string{"b64"}
+
+// registeredHeaders are the header parameters defined by RFC 7515, 7516 and
+// 7518, which must not appear in "crit" (RFC 7515 section 4.1.11).
+var registeredHeaders = []string{
+	"alg", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256", "typ", "cty", "crit",
+	"enc", "zip", "epk", "apu", "apv", "iv", "tag", "p2s", "p2c",
+}
+
+// parseOptions carries a Verifier's parsing settings to the shared parsers.
+type parseOptions struct {
+	limits   Limits
+	critical []string // extensions understood by the caller
+}
+
+func (v *Verifier) parseOptions() parseOptions {
+	if v == nil {
+		return parseOptions{}
+	}
+	return parseOptions{limits: v.Limits, critical: v.Critical}
+}
+
+// checkSize rejects an encoded token of n bytes.
+func (po parseOptions) checkSize(n int) error {
+	if n > po.limits.token() {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrTokenTooLarge, n, po.limits.token())
+	}
+	return nil
+}
+
+// decodeSegment base64url-decodes seg after checking that the result fits in
+// max bytes, so oversized input is rejected without allocating for it.
+func decodeSegment(seg, what string, max int) ([]byte, error) {
+	if n := base64.RawURLEncoding.DecodedLen(len(seg)); n > max {
+		return nil, fmt.Errorf("%w: %s is %d bytes, max %d", ErrTokenTooLarge, what, n, max)
+	}
+	b, err := b64urlDecode(seg)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: decode %s: %w", what, err)
+	}
+	return b, nil
+}
+
+// decodeHeader decodes and parses an encoded protected header.
+func (po parseOptions) decodeHeader(seg string) (map[string]any, error) {
+	raw, err := decodeSegment(seg, "header", po.limits.header())
+	if err != nil {
+		return nil, err
+	}
+	var hdr map[string]any
+	if err := unmarshalStrict(raw, &hdr); err != nil {
+		return nil, fmt.Errorf("jwt: parse header json: %w", err)
+	}
+	if hdr == nil {
+		return nil, errors.New("jwt: header is not a json object")
+	}
+	return hdr, nil
+}
+
+// unmarshalStrict is json.Unmarshal that first rejects duplicate member names
+// (which encoding/json resolves silently, last one wins, so two parsers
+// could disagree about a token) and deep nesting. In the top-level object,
+// names differing only in case count as duplicates too, because the header
+// and claims are decoded into structs, whose fields match names
+// case-insensitively; nested objects are decoded into case-sensitive maps.
+func unmarshalStrict(raw []byte, v any) error {
+	if err := checkStrictJSON(raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// checkStrictJSON reports the problems unmarshalStrict rejects.
+func checkStrictJSON(raw []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if err := checkJSON(dec, 0); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("jwt: trailing data after json value")
+	}
+	return nil
+}
+
+// checkJSON walks one JSON value from dec.
+func checkJSON(dec *json.Decoder, depth int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	if depth >= maxJSONDepth {
+		return errors.New("jwt: json nested too deeply")
+	}
+	var seen map[string]bool
+	if delim == '{' {
+		seen = map[string]bool{}
+	}
+	for dec.More() {
+		if seen != nil {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := tok.(string)
+			key := name
+			if depth == 0 {
+				key = foldName(name)
+			}
+			if seen[key] {
+				return fmt.Errorf("%w %q", ErrDuplicateKey, name)
+			}
+			seen[key] = true
+		}
+		if err := checkJSON(dec, depth+1); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // closing delimiter
+	return err
+}
+
+// foldName folds name the way encoding/json matches struct field names.
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune { return unicode.ToUpper(unicode.ToLower(r)) }, name)
+}
+
+// checkCrit enforces RFC 7515 section 4.1.11: "crit" must be a non-empty
+// array of names that are present in the header, not registered header
+// parameters, and understood.
+func checkCrit(protected map[string]any, understood []string) error {
+	raw, ok := protected["crit"]
+	if !ok {
+		return nil
+	}
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return errors.New(`jwt: "crit" must be a non-empty array`)
+	}
+	seen := map[string]bool{}
+	for _, v := range list {
+		name, ok := v.(string)
+		if !ok || name == "" {
+			return errors.New(`jwt: "crit" must contain strings`)
+		}
+		if seen[name] || slices.Contains(registeredHeaders, name) {
+			return fmt.Errorf(`jwt: invalid "crit" entry %q`, name)
+		}
+		seen[name] = true
+		if _, present := protected[name]; !present {
+			return fmt.Errorf("jwt: critical header %q is missing", name)
+		}
+		if !slices.Contains(understood, name) {
+			return fmt.Errorf("%w: %q", ErrCritical, name)
+		}
+	}
+	return nil
+}
+
+func critLists(protected map[string]any, name string) bool {
+	list, _ := protected["crit"].([]any)
+	return slices.Contains(list, any(name))
+}
@@ -0,0 +1,207 @@
+package jwtverify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var parseTestKey = []byte("0123456789abcdef0123456789abcdef")
+
+// rawHS256 signs raw header and payload JSON, which may be malformed.
+func rawHS256(header, payload string) string {
+	input := b64url([]byte(header)) + "." + b64url([]byte(payload))
+	mac := hmac.New(sha256.New, parseTestKey)
+	mac.Write([]byte(input))
+	return input + "." + b64url(mac.Sum(nil))
+}
+
+func TestVerify_Crit(t *testing.T) {
+	ext := rawHS256(`{"alg":"HS256","crit":["ext"],"ext":1}`, `{"sub":"a"}`)
+	if _, _, err := Verify(ext, parseTestKey); !errors.Is(err, ErrCritical) {
+		t.Fatalf("unknown crit: %v", err)
+	}
+	v := &Verifier{Algorithms: []string{"HS256"}, Critical: []string{"ext"}}
+	if hdr, _, err := v.Verify(ext, parseTestKey); err != nil || hdr["ext"] != float64(1) {
+		t.Fatalf("registered crit: %v %v", hdr, err)
+	}
+
+	for _, hdr := range []string{
+		`{"alg":"HS256","crit":["alg"]}`,
+		`{"alg":"HS256","crit":"ext","ext":1}`,
+		`{"alg":"HS256","crit":["ext"]}`,
+	} {
+		if _, _, err := v.Verify(rawHS256(hdr, `{}`), parseTestKey); err == nil {
+			t.Errorf("%s accepted", hdr)
+		}
+	}
+
+	// RFC 7797 in compact form: the payload segment is the raw JSON.
+	hdr := b64url([]byte(`{"alg":"HS256","b64":false,"crit":["b64"]}`))
+	payload := `{"sub":"a"}`
+	mac := hmac.New(sha256.New, parseTestKey)
+	mac.Write([]byte(hdr + "." + payload))
+	tok := hdr + "." + payload + "." + b64url(mac.Sum(nil))
+	if _, claims, err := Verify(tok, parseTestKey); err != nil || claims["sub"] != "a" {
+		t.Fatalf("b64 false: %v %v", claims, err)
+	}
+}
+
+func TestVerify_DuplicateKeys(t *testing.T) {
+	for _, tc := range []struct{ header, payload string }{
+		{`{"alg":"HS256","alg":"none"}`, `{}`},
+		{`{"alg":"HS256"}`, `{"sub":"alice","sub":"admin"}`},
+		{`{"alg":"HS256"}`, `{"cnf":{"jkt":"a","jkt":"b"}}`},
+		{`{"alg":"HS256"}`, `{"list":[{"a":1,"a":2}]}`},
+		{`{"alg":"HS256"}`, `{"sub":"alice","Sub":"mallory"}`},
+		{`{"alg":"HS256","kid":"a","KID":"b"}`, `{}`},
+	} {
+		_, _, err := Verify(rawHS256(tc.header, tc.payload), parseTestKey)
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("%s %s: %v", tc.header, tc.payload, err)
+		}
+	}
+
+	// Nested custom claims are decoded into maps, where case matters.
+	if _, claims, err := Verify(rawHS256(`{"alg":"HS256"}`, `{"ext":{"ID":1,"id":2}}`), parseTestKey); err != nil || claims["ext"].(map[string]any)["id"] != float64(2) {
+		t.Errorf("nested case variants: %v, %v", claims, err)
+	}
+	if _, _, err := Verify(rawHS256(`{"alg":"HS256"}`, `{"ext":{"id":1,"id":2}}`), parseTestKey); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("nested exact duplicate: %v", err)
+	}
+
+	// Map and struct decoding must not see different claims.
+	caseVariant := rawHS256(`{"alg":"HS256"}`, `{"sub":"alice","Sub":"mallory"}`)
+	if c, err := VerifyInto[RegisteredClaims](caseVariant, parseTestKey); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("VerifyInto: %+v, %v", c, err)
+	}
+	h := Middleware[RegisteredClaims](AuthOptions{Keys: StaticKey{Key: parseTestKey}})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) { t.Error("handler called") }))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+caseVariant)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("middleware status %d", rec.Code)
+	}
+
+	if _, _, err := Verify(rawHS256(`{"alg":"HS256"}`, `{"a":`+strings.Repeat("[", 100)+strings.Repeat("]", 100)+`}`), parseTestKey); err == nil {
+		t.Error("deep nesting accepted")
+	}
+	if _, _, err := Verify(rawHS256(`{"alg":"HS256"}`, `[1]`), parseTestKey); err == nil {
+		t.Error("non-object payload accepted")
+	}
+}
+
+func TestVerify_Limits(t *testing.T) {
+	big := rawHS256(`{"alg":"HS256"}`, `{"pad":"`+strings.Repeat("x", 70<<10)+`"}`)
+	if _, _, err := Verify(big, parseTestKey); !errors.Is(err, ErrTokenTooLarge) {
+		t.Fatalf("default token limit: %v", err)
+	}
+	v := &Verifier{Algorithms: []string{"HS256"}, Limits: Limits{MaxTokenSize: 1 << 20, MaxPayloadSize: 1 << 20}}
+	if _, _, err := v.Verify(big, parseTestKey); err != nil {
+		t.Fatalf("raised limits: %v", err)
+	}
+
+	small := &Verifier{Algorithms: []string{"HS256"}, Limits: Limits{MaxHeaderSize: 16, MaxPayloadSize: 16}}
+	for _, tok := range []string{
+		rawHS256(`{"alg":"HS256","kid":"a-long-key-id"}`, `{}`),
+		rawHS256(`{"alg":"HS256"}`, `{"sub":"a-long-subject"}`),
+	} {
+		if _, _, err := small.Verify(tok, parseTestKey); !errors.Is(err, ErrTokenTooLarge) {
+			t.Errorf("small limits: %v", err)
+		}
+	}
+	if _, _, err := Decrypt(strings.Repeat("a", 70<<10), parseTestKey); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("jwe limit: %v", err)
+	}
+}
+
+func TestVerify_NonCanonicalBase64(t *testing.T) {
+	tok := rawHS256(`{"alg":"HS256"}`, `{"sub":"a"}`)
+	sig := tok[strings.LastIndex(tok, ".")+1:]
+	// The last character of a 32-byte value carries 2 unused (zero) bits.
+	last := strings.IndexByte(b64Alphabet, sig[len(sig)-1])
+	alt := tok[:len(tok)-1] + string(b64Alphabet[last|1])
+	if _, _, err := Verify(alt, parseTestKey); err == nil {
+		t.Error("non-canonical signature encoding accepted")
+	}
+	if _, _, err := Verify(strings.Replace(tok, ".", ".\n", 1), parseTestKey); err == nil {
+		t.Error("newline accepted")
+	}
+}
+
+const b64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func TestDecrypt_Crit(t *testing.T) {
+	key := make([]byte, 32)
+	tok, err := Encrypt(map[string]any{"alg": "dir", "enc": "A256GCM", "crit": []string{"ext"}, "ext": true}, []byte("x"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Decrypt(tok, key); !errors.Is(err, ErrCritical) {
+		t.Fatalf("got %v", err)
+	}
+	v := &Verifier{Critical: []string{"ext"}}
+	if hdr, pt, err := v.Decrypt(tok, key); err != nil || hdr["ext"] != true || string(pt) != "x" {
+		t.Fatalf("registered crit: %v %q %v", hdr, pt, err)
+	}
+	v.Limits = Limits{MaxTokenSize: 32}
+	if _, _, err := v.Decrypt(tok, key); !errors.Is(err, ErrTokenTooLarge) {
+		t.Fatalf("limits: %v", err)
+	}
+}
+
+func FuzzVerify(f *testing.F) {
+	seeds := []string{
+		rawHS256(`{"alg":"HS256"}`, `{"sub":"a","aud":["x","y"],"exp":1}`),
+		rawHS256(`{"alg":"HS256","b64":false,"crit":["b64"]}`, `{}`),
+		rawHS256(`{"alg":"HS256","kid":"k"}`, `{"nested":{"a":[1,2,{"b":null}]}}`),
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		hdr, claims, err := Verify(token, parseTestKey)
+		if err != nil {
+			return
+		}
+		// Only the genuine tokens carry a valid MAC, and strict decoding
+		// leaves no alternative encodings of them.
+		found := false
+		for _, s := range seeds {
+			found = found || s == token
+		}
+		if !found {
+			t.Fatalf("forged token accepted: %q (%v, %v)", token, hdr, claims)
+		}
+	})
+}
+
+func FuzzVerifyJSON(f *testing.F) {
+	signed, err := SignJSON([]byte(`{"a":1}`), JSONOptions{}, JSONSigner{Key: parseTestKey})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(signed)
+	f.Add([]byte(`{"payload":"e30","signatures":[{"protected":"eyJhbGciOiJIUzI1NiJ9","header":{"kid":"a"},"signature":""}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		VerifyJSON(data, nil, parseTestKey)
+	})
+}
+
+func FuzzCheckStrictJSON(f *testing.F) {
+	for _, s := range []string{`{}`, `{"a":{"b":[1,"c",null]}}`, `{"a":1,"a":2}`, `[[[]]]`, `"s"`, `{"a":1} x`} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if checkStrictJSON(data) == nil && !json.Valid(data) {
+			t.Fatalf("accepted invalid json %q", data)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package jwtverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ParsePEMKey parses the first key PEM block in data into a key usable with
+// Verify (public keys) or Sign and Decrypt (private keys). Other blocks, such
+// as the "EC PARAMETERS" that openssl ecparam -genkey emits, are skipped.
+//
+// Supported blocks: "PUBLIC KEY" (PKIX), "RSA PUBLIC KEY" (PKCS#1),
+// "CERTIFICATE" (its public key), "PRIVATE KEY" (PKCS#8), "RSA PRIVATE KEY"
+// (PKCS#1) and "EC PRIVATE KEY" (SEC 1). Only RSA, ECDSA and Ed25519 keys are
+// returned.
+func ParsePEMKey(data []byte) (any, error) {
+	var skipped []string
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if key, ok, err := parsePEMBlock(block); ok {
+			return key, err
+		}
+		skipped = append(skipped, block.Type)
+	}
+	if len(skipped) > 0 {
+		return nil, fmt.Errorf("jwt: no supported PEM key block (found %q)", skipped)
+	}
+	return nil, errors.New("jwt: no PEM block found")
+}
+
+// parsePEMBlock parses a key block; ok is false for unsupported block types.
+func parsePEMBlock(block *pem.Block) (key any, ok bool, err error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("jwt: parse %s: %w", block.Type, err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey,
+		*rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, true, nil
+	default:
+		return nil, true, fmt.Errorf("jwt: unsupported key type %T", key)
+	}
+}
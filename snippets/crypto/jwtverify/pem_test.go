@@ -0,0 +1,127 @@
+package jwtverify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePEMKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkixDER, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test"}, NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, edPub, edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for typ, tc := range map[string]struct {
+		der  []byte
+		want crypto.PublicKey
+	}{
+		"PUBLIC KEY":      {pkixDER, &ecKey.PublicKey},
+		"RSA PUBLIC KEY":  {x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), &rsaKey.PublicKey},
+		"CERTIFICATE":     {cert, edPub},
+		"PRIVATE KEY":     {pkcs8, edKey},
+		"RSA PRIVATE KEY": {x509.MarshalPKCS1PrivateKey(rsaKey), rsaKey},
+		"EC PRIVATE KEY":  {sec1, ecKey},
+	} {
+		key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: tc.der}))
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		if !keysEqual(key, tc.want) {
+			t.Fatalf("%s: got %T, not equal to the original key", typ, key)
+		}
+	}
+	if _, err := ParsePEMKey([]byte("not pem")); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// openssl ecparam -genkey puts the curve parameters first.
+	params := pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}})
+	ecparam := append(params, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})...)
+	if key, err := ParsePEMKey(ecparam); err != nil || !keysEqual(key, ecKey) {
+		t.Fatalf("ecparam output: %T %v", key, err)
+	}
+	if _, err := ParsePEMKey(params); err == nil || !strings.Contains(err.Error(), "EC PARAMETERS") {
+		t.Fatalf("parameters only: %v", err)
+	}
+}
+
+func TestJWK_RoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, priv := range []crypto.Signer{rsaKey, ecKey, edKey} {
+		jwk, err := NewJWK(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk.Use = "sig"
+		b, err := json.Marshal(jwk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), `"d"`) {
+			t.Fatalf("private key material exported: %s", b)
+		}
+		back, err := ParseJWK(b)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if !keysEqual(priv.Public(), back.Key) || back.KeyID != jwk.KeyID || back.Use != "sig" {
+			t.Fatalf("round trip: %+v", back)
+		}
+		if kid, _ := Thumbprint(back.Key); kid != jwk.KeyID {
+			t.Fatalf("thumbprint of public and private key differ")
+		}
+	}
+
+	set, _ := MarshalJWKS(JWK{KeyID: "a", Key: &ecKey.PublicKey})
+	if keys, err := ParseJWKS(set); err != nil || len(keys) != 1 || keys[0].KeyID != "a" {
+		t.Fatalf("MarshalJWKS round trip: %v %v", keys, err)
+	}
+}
+
+func TestParseJWK_Oct(t *testing.T) {
+	jwk, err := ParseJWK([]byte(`{"kty":"oct","k":"GawgguFyGrWKav7AX4VKUg","alg":"A128KW"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, ok := jwk.Key.([]byte); !ok || len(k) != 16 || jwk.Algorithm != "A128KW" {
+		t.Fatalf("jwk = %+v", jwk)
+	}
+	if _, err := json.Marshal(jwk); err == nil {
+		t.Fatal("symmetric key was exported")
+	}
+	if _, err := ParseJWK([]byte(`{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}`)); err == nil {
+		t.Fatal("invalid EC key accepted")
+	}
+}
+
+func keysEqual(a, b any) bool {
+	switch k := a.(type) {
+	case interface{ Equal(crypto.PublicKey) bool }:
+		return k.Equal(b)
+	case interface{ Equal(crypto.PrivateKey) bool }:
+		return k.Equal(b)
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package jwtverify
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Policy errors.
+var (
+	ErrAlgNotAllowed = errors.New("jwt: algorithm not allowed")
+	ErrWeakKey       = errors.New("jwt: key does not meet policy")
+)
+
+// Verifier verifies tokens like Verify, but only with pinned algorithms
+// instead of whatever the (attacker-controlled) header names.
+//
+// Keys passed to Verify may be bound to algorithms: a JWK with an "alg" only
+// verifies that algorithm, and a BoundKey only its Algorithms. Unbound keys
+// verify any allowed algorithm of their type, so an RSA key still accepts
+// both RS* and PS* unless bound.
+type Verifier struct {
+	// Algorithms is the allowlist, e.g. []string{"ES256"}. It must not be
+	// empty.
+	Algorithms []string
+	// MinRSABits is the minimum RSA modulus size. Default 2048.
+	MinRSABits int
+	// Limits bounds token, header and payload sizes.
+	Limits Limits
+	// Critical lists "crit" header extensions the caller understands and
+	// checks itself in the returned header. Tokens marking any other
+	// extension critical are rejected with ErrCritical.
+	Critical []string
+}
+
+// BoundKey restricts Key to the listed algorithms.
+type BoundKey struct {
+	Key        any
+	Algorithms []string
+}
+
+// Verify checks the token's alg against the policy and the key binding, then
+// verifies it with Verify. ECDSA keys must be on P-256, P-384 or P-521 and
+// match the alg's curve.
+func (v *Verifier) Verify(token string, key any) (header, payload map[string]any, err error) {
+	header, rawPayload, err := v.verifyCompact(token, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, nil, fmt.Errorf("jwt: parse payload json: %w", err)
+	}
+	return header, payload, nil
+}
+
+func (v *Verifier) verifyCompact(token string, key any) (header map[string]any, payload []byte, err error) {
+	k, err := v.check(token, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return verifyCompact(token, k, v.parseOptions())
+}
+
+// check enforces the policy for token and key and returns the unwrapped key.
+func (v *Verifier) check(token string, key any) (any, error) {
+	hdr, err := peekHeader(token, v.parseOptions())
+	if err != nil {
+		return nil, err
+	}
+	alg, _ := hdr["alg"].(string)
+	return v.checkKey(alg, key)
+}
+
+// checkKey enforces the policy for alg and key and returns the unwrapped key.
+func (v *Verifier) checkKey(alg string, key any) (any, error) {
+	if !slices.Contains(v.Algorithms, alg) {
+		return nil, fmt.Errorf("%w: %q", ErrAlgNotAllowed, alg)
+	}
+
+	k, bound := unbindKey(key)
+	if len(bound) > 0 && !slices.Contains(bound, alg) {
+		return nil, fmt.Errorf("%w: %q for key bound to %q", ErrAlgNotAllowed, alg, bound)
+	}
+	if !keyFitsAlg(k, alg) {
+		return nil, fmt.Errorf("%w: %q for key type %T", ErrAlgNotAllowed, alg, k)
+	}
+	if pub, ok := k.(*rsa.PublicKey); ok {
+		minBits := v.MinRSABits
+		if minBits <= 0 {
+			minBits = 2048
+		}
+		if pub.N.BitLen() < minBits {
+			return nil, fmt.Errorf("%w: RSA key has %d bits, need %d", ErrWeakKey, pub.N.BitLen(), minBits)
+		}
+	}
+	return k, nil
+}
+
+// unbindKey returns the key inside a JWK or BoundKey and the algorithms it is
+// bound to, if any.
+func unbindKey(key any) (any, []string) {
+	switch k := key.(type) {
+	case BoundKey:
+		return k.Key, k.Algorithms
+	case *BoundKey:
+		return k.Key, k.Algorithms
+	case JWK:
+		return k.Key, algList(k.Algorithm)
+	case *JWK:
+		return k.Key, algList(k.Algorithm)
+	}
+	return key, nil
+}
+
+func algList(alg string) []string {
+	if alg == "" {
+		return nil
+	}
+	return []string{alg}
+}
@@ -0,0 +1,92 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestVerifier_Allowlist(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rs256, _ := Sign(map[string]any{"alg": "RS256"}, map[string]any{}, rsaKey)
+	ps256, _ := Sign(map[string]any{"alg": "PS256"}, map[string]any{}, rsaKey)
+	pub := &rsaKey.PublicKey
+
+	v := &Verifier{Algorithms: []string{"RS256", "PS256"}}
+	if _, _, err := v.Verify(rs256, pub); err != nil {
+		t.Fatalf("RS256: %v", err)
+	}
+	if _, _, err := (&Verifier{Algorithms: []string{"RS256"}}).Verify(ps256, pub); !errors.Is(err, ErrAlgNotAllowed) {
+		t.Fatalf("PS256 outside allowlist: got %v", err)
+	}
+	if _, _, err := (&Verifier{}).Verify(rs256, pub); !errors.Is(err, ErrAlgNotAllowed) {
+		t.Fatalf("empty allowlist: got %v", err)
+	}
+
+	// Binding: a key bound to RS256 must not verify PS256 even if allowed.
+	for name, key := range map[string]any{
+		"BoundKey": BoundKey{Key: pub, Algorithms: []string{"RS256"}},
+		"JWK":      JWK{Key: pub, Algorithm: "RS256"},
+	} {
+		if _, _, err := v.Verify(rs256, key); err != nil {
+			t.Fatalf("%s RS256: %v", name, err)
+		}
+		if _, _, err := v.Verify(ps256, key); !errors.Is(err, ErrAlgNotAllowed) {
+			t.Fatalf("%s PS256: got %v", name, err)
+		}
+	}
+}
+
+func TestVerifier_KeyStrength(t *testing.T) {
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	tok, _ := Sign(map[string]any{"alg": "RS256"}, map[string]any{}, small)
+	v := &Verifier{Algorithms: []string{"RS256"}}
+	if _, _, err := v.Verify(tok, &small.PublicKey); !errors.Is(err, ErrWeakKey) {
+		t.Fatalf("1024-bit RSA: got %v", err)
+	}
+	v.MinRSABits = 1024
+	if _, _, err := v.Verify(tok, &small.PublicKey); err != nil {
+		t.Fatalf("MinRSABits=1024: %v", err)
+	}
+
+	// ES256 header with a P-384 key, and a non-approved curve.
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	es, _ := Sign(map[string]any{"alg": "ES384"}, map[string]any{}, p384)
+	v = &Verifier{Algorithms: []string{"ES256", "ES384"}}
+	if _, _, err := v.Verify(es, &p384.PublicKey); err != nil {
+		t.Fatalf("ES384: %v", err)
+	}
+	if _, _, err := v.Verify(es, &p224.PublicKey); !errors.Is(err, ErrAlgNotAllowed) {
+		t.Fatalf("P-224 key: got %v", err)
+	}
+}
+
+func TestKeySet_VerifierBindsJWKAlg(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwk := jwkJSON("r", &rsaKey.PublicKey)
+	jwk["alg"] = "PS256"
+	srv := newJWKSServer(t)
+	srv.set("", false, jwk, jwkJSON("e", edKey.Public()))
+
+	ks := NewKeySet(srv.URL, KeySetOptions{Verifier: &Verifier{Algorithms: []string{"PS256", "RS256"}}})
+	ctx := context.Background()
+	ps, _ := Sign(map[string]any{"alg": "PS256", "kid": "r"}, map[string]any{}, rsaKey)
+	if _, _, err := ks.Verify(ctx, ps); err != nil {
+		t.Fatalf("PS256: %v", err)
+	}
+	rs, _ := Sign(map[string]any{"alg": "RS256", "kid": "r"}, map[string]any{}, rsaKey)
+	if _, _, err := ks.Verify(ctx, rs); err == nil {
+		t.Fatal("RS256 accepted for a key published as PS256")
+	}
+	ed, _ := Sign(map[string]any{"kid": "e"}, map[string]any{}, edKey)
+	if _, _, err := ks.Verify(ctx, ed); !errors.Is(err, ErrAlgNotAllowed) {
+		t.Fatalf("EdDSA outside allowlist: got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/io/atomicfile"
+)
+
+// ErrReplay is returned by ReplayDetector for a token that was already
+// accepted.
+var ErrReplay = errors.New("jwt: token replayed")
+
+// ReplayStore remembers the IDs of accepted tokens. Implementations backed by
+// a shared cache (e.g. Redis SET NX with an expiry) let several instances
+// reject each other's replays.
+type ReplayStore interface {
+	// Add records id until expires. It returns false if id is already
+	// present and has not expired.
+	Add(ctx context.Context, id string, expires time.Time) (bool, error)
+}
+
+// ReplayOptions configures a ReplayDetector.
+type ReplayOptions struct {
+	// Store remembers seen tokens. Defaults to a MemoryReplayStore.
+	Store ReplayStore
+	// Leeway extends how long a token is remembered past its "exp". Use the
+	// Validator's Leeway so a token is remembered for as long as it validates.
+	Leeway time.Duration
+	// RequireJTI rejects tokens without a "jti". Otherwise they are keyed by
+	// a hash of the token, which only catches byte-for-byte replays.
+	RequireJTI bool
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// ReplayDetector rejects one-time tokens (password reset links, magic links,
+// DPoP proofs) that were seen before. Check tokens after verifying the
+// signature and validating the claims, so forged or expired tokens never
+// occupy the store.
+type ReplayDetector struct {
+	opt ReplayOptions
+}
+
+// NewReplayDetector creates a ReplayDetector.
+func NewReplayDetector(opt ReplayOptions) *ReplayDetector {
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	if opt.Store == nil {
+		opt.Store = newMemoryReplayStore(opt.Now)
+	}
+	return &ReplayDetector{opt: opt}
+}
+
+// Check records the token and returns ErrReplay if it was seen before.
+// claims are the token's verified claims; "exp" is required, since the token
+// must be remembered until it expires.
+func (d *ReplayDetector) Check(ctx context.Context, token string, claims map[string]any) error {
+	exp, ok, err := numericDateClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrMissingClaim, "exp")
+	}
+	iss, _, err := stringClaim(claims, "iss")
+	if err != nil {
+		return err
+	}
+	jti, _, err := stringClaim(claims, "jti")
+	if err != nil {
+		return err
+	}
+	return d.check(ctx, token, iss, jti, exp)
+}
+
+// CheckRegistered is Check for typed claims.
+func (d *ReplayDetector) CheckRegistered(ctx context.Context, token string, c *RegisteredClaims) error {
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: %q", ErrMissingClaim, "exp")
+	}
+	return d.check(ctx, token, c.Issuer, c.ID, c.ExpiresAt.Time)
+}
+
+func (d *ReplayDetector) check(ctx context.Context, token, iss, jti string, exp time.Time) error {
+	if jti == "" && d.opt.RequireJTI {
+		return fmt.Errorf("%w: %q", ErrMissingClaim, "jti")
+	}
+	expires := exp.Add(d.opt.Leeway)
+	if !d.opt.Now().Before(expires) {
+		return ErrTokenExpired // nothing to remember; it can no longer be used
+	}
+	added, err := d.opt.Store.Add(ctx, replayKey(token, iss, jti), expires)
+	if err != nil {
+		return fmt.Errorf("jwt: replay store: %w", err)
+	}
+	if !added {
+		return ErrReplay
+	}
+	return nil
+}
+
+// replayKey derives a fixed-size store key. "jti" is only unique per issuer.
+func replayKey(token, iss, jti string) string {
+	var sum [sha256.Size]byte
+	if jti != "" {
+		sum = sha256.Sum256([]byte("jti\x00" + iss + "\x00" + jti))
+	} else {
+		sum = sha256.Sum256([]byte("token\x00" + token))
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	replayShards        = 32
+	replayPurgeInterval = time.Minute
+)
+
+// MemoryReplayStore is an in-process ReplayStore. It is sharded to reduce
+// lock contention; expired IDs are purged lazily.
+type MemoryReplayStore struct {
+	shards [replayShards]replayShard
+	now    func() time.Time
+}
+
+type replayShard struct {
+	mu        sync.Mutex
+	ids       map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryReplayStore returns an empty MemoryReplayStore.
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return newMemoryReplayStore(time.Now)
+}
+
+func newMemoryReplayStore(now func() time.Time) *MemoryReplayStore {
+	s := &MemoryReplayStore{now: now}
+	for i := range s.shards {
+		s.shards[i].ids = make(map[string]time.Time)
+	}
+	return s
+}
+
+func (s *MemoryReplayStore) shard(id string) *replayShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &s.shards[h.Sum32()%replayShards]
+}
+
+// Add implements ReplayStore.
+func (s *MemoryReplayStore) Add(_ context.Context, id string, expires time.Time) (bool, error) {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := s.now()
+	if now.Sub(sh.lastPurge) > replayPurgeInterval {
+		sh.purge(now)
+		sh.lastPurge = now
+	}
+	if exp, ok := sh.ids[id]; ok && now.Before(exp) {
+		return false, nil
+	}
+	sh.ids[id] = expires
+	return true, nil
+}
+
+// Len returns the number of remembered IDs, including expired ones not yet
+// purged.
+func (s *MemoryReplayStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.ids)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+func (sh *replayShard) purge(now time.Time) {
+	for k, exp := range sh.ids {
+		if !now.Before(exp) {
+			delete(sh.ids, k)
+		}
+	}
+}
+
+// replaySnapshot is the on-disk format of a MemoryReplayStore.
+type replaySnapshot struct {
+	Version int              `json:"version"`
+	IDs     map[string]int64 `json:"ids"` // id -> expiry, Unix seconds
+}
+
+// SaveFile writes the unexpired IDs to path atomically, so a restarted
+// process can keep rejecting replays (see LoadFile). Call it periodically
+// and on shutdown.
+func (s *MemoryReplayStore) SaveFile(path string) error {
+	snap := replaySnapshot{Version: 1, IDs: map[string]int64{}}
+	now := s.now()
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for id, exp := range sh.ids {
+			if now.Before(exp) {
+				// Round up so an ID is never forgotten early.
+				snap.IDs[id] = exp.Add(time.Second - 1).Unix()
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return atomicfile.WriteFileFunc(path, 0o600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snap)
+	})
+}
+
+// LoadFile adds the unexpired IDs saved by SaveFile. A missing file is not
+// an error.
+func (s *MemoryReplayStore) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap replaySnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("jwt: parse replay snapshot: %w", err)
+	}
+	if snap.Version != 1 {
+		return fmt.Errorf("jwt: unsupported replay snapshot version %d", snap.Version)
+	}
+	now := s.now()
+	for id, unix := range snap.IDs {
+		exp := time.Unix(unix, 0)
+		if !now.Before(exp) {
+			continue
+		}
+		sh := s.shard(id)
+		sh.mu.Lock()
+		if cur, ok := sh.ids[id]; !ok || cur.Before(exp) {
+			sh.ids[id] = exp
+		}
+		sh.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package jwtverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// Sign produces a compact JWS for claims.
+//
+// key is an *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey or a
+// []byte HMAC secret. header may be nil; it is copied, not modified. nil
+// claims are signed as an empty object. Missing header parameters are filled
+// in:
+//   - "alg": RS256 for RSA, ES256/ES384/ES512 by curve, EdDSA, or HS256
+//   - "typ": "JWT"
+//   - "kid": the RFC 7638 thumbprint of the public key (not for HMAC)
+//
+// Sign returns an error if an explicit alg does not fit the key.
+func Sign(header, claims map[string]any, key any) (string, error) {
+	hdr := maps.Clone(header)
+	if hdr == nil {
+		hdr = map[string]any{}
+	}
+	alg, _ := hdr["alg"].(string)
+	if alg == "" {
+		var err error
+		if alg, err = defaultAlg(key); err != nil {
+			return "", err
+		}
+		hdr["alg"] = alg
+	}
+	if _, ok := hdr["typ"]; !ok {
+		hdr["typ"] = "JWT"
+	}
+	if _, ok := hdr["kid"]; !ok {
+		if pub := publicKeyOf(key); pub != nil {
+			if kid, err := Thumbprint(pub); err == nil {
+				hdr["kid"] = kid
+			}
+		}
+	}
+
+	rawHdr, err := json.Marshal(hdr)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode header: %w", err)
+	}
+	if claims == nil {
+		claims = map[string]any{} // "null" is not a valid claims set
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode claims: %w", err)
+	}
+	signingInput := b64urlEncode(rawHdr) + "." + b64urlEncode(rawClaims)
+	sig, err := signJWS(alg, signingInput, key)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64urlEncode(sig), nil
+}
+
+func signJWS(alg, signingInput string, key any) ([]byte, error) {
+	if alg == "EdDSA" {
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: EdDSA requires ed25519.PrivateKey")
+		}
+		return ed25519.Sign(priv, []byte(signingInput)), nil
+	}
+	h, err := hashForAlg(alg)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: unsupported alg %q", alg)
+	}
+	switch alg {
+	case "RS256", "RS384", "RS512":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: RS* requires *rsa.PrivateKey")
+		}
+		return rsa.SignPKCS1v15(rand.Reader, priv, h, digest(h, []byte(signingInput)))
+	case "PS256", "PS384", "PS512":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: PS* requires *rsa.PrivateKey")
+		}
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h}
+		return rsa.SignPSS(rand.Reader, priv, h, digest(h, []byte(signingInput)), opts)
+	case "ES256", "ES384", "ES512":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: ES* requires *ecdsa.PrivateKey")
+		}
+		if !keyFitsAlg(&priv.PublicKey, alg) {
+			return nil, fmt.Errorf("jwt: %s requires curve %s", alg, curveForAlg(alg))
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest(h, []byte(signingInput)))
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses raw (r||s), each left-padded to the curve size.
+		sz := (priv.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*sz)
+		r.FillBytes(sig[:sz])
+		s.FillBytes(sig[sz:])
+		return sig, nil
+	case "HS256", "HS384", "HS512":
+		secret, err := hmacSecret(key, h)
+		if err != nil {
+			return nil, err
+		}
+		mac := hmac.New(h.New, secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported alg %q", alg)
+	}
+}
+
+func defaultAlg(key any) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("jwt: unsupported curve %s", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	case []byte:
+		return "HS256", nil
+	default:
+		return "", fmt.Errorf("jwt: unsupported signing key type %T", key)
+	}
+}
+
+func curveForAlg(alg string) string {
+	switch alg {
+	case "ES256":
+		return "P-256"
+	case "ES384":
+		return "P-384"
+	default:
+		return "P-521"
+	}
+}
+
+// publicKeyOf returns the public half of an asymmetric private key, or nil.
+func publicKeyOf(key any) any {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return nil
+}
+
+func b64urlEncode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,119 @@
+package jwtverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+func TestSign_RoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p521, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := make([]byte, 64)
+	rand.Read(secret)
+
+	for _, tc := range []struct {
+		alg       string
+		priv, pub any
+		sigLen    int
+	}{
+		{"RS256", rsaKey, &rsaKey.PublicKey, 256},
+		{"RS384", rsaKey, &rsaKey.PublicKey, 256},
+		{"RS512", rsaKey, &rsaKey.PublicKey, 256},
+		{"PS256", rsaKey, &rsaKey.PublicKey, 256},
+		{"PS384", rsaKey, &rsaKey.PublicKey, 256},
+		{"PS512", rsaKey, &rsaKey.PublicKey, 256},
+		{"ES256", p256, &p256.PublicKey, 64},
+		{"ES384", p384, &p384.PublicKey, 96},
+		{"ES512", p521, &p521.PublicKey, 132},
+		{"EdDSA", edKey, edKey.Public(), 64},
+		{"HS256", secret, secret, 32},
+		{"HS384", secret, secret, 48},
+		{"HS512", secret, secret, 64},
+	} {
+		tok, err := Sign(map[string]any{"alg": tc.alg}, map[string]any{"sub": "42"}, tc.priv)
+		if err != nil {
+			t.Fatalf("%s: Sign: %v", tc.alg, err)
+		}
+		sig, _ := b64urlDecode(tok[strings.LastIndexByte(tok, '.')+1:])
+		if len(sig) != tc.sigLen {
+			t.Errorf("%s: signature is %d bytes, want %d", tc.alg, len(sig), tc.sigLen)
+		}
+		h, p, err := Verify(tok, tc.pub)
+		if err != nil {
+			t.Fatalf("%s: Verify: %v", tc.alg, err)
+		}
+		if h["alg"] != tc.alg || p["sub"] != "42" {
+			t.Fatalf("%s: header %v payload %v", tc.alg, h, p)
+		}
+	}
+}
+
+func TestSign_DefaultHeaders(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	tok, err := Sign(nil, map[string]any{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := Verify(tok, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := Thumbprint(&key.PublicKey)
+	if h["alg"] != "ES384" || h["typ"] != "JWT" || h["kid"] != kid {
+		t.Fatalf("header = %v", h)
+	}
+
+	custom := map[string]any{"kid": "mine", "typ": "at+jwt"}
+	tok, _ = Sign(custom, map[string]any{}, key)
+	h, _, _ = Verify(tok, &key.PublicKey)
+	if h["kid"] != "mine" || h["typ"] != "at+jwt" {
+		t.Fatalf("explicit header overridden: %v", h)
+	}
+	if _, ok := custom["alg"]; ok {
+		t.Fatal("Sign modified the caller's header")
+	}
+	tok, err = Sign(nil, nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, claims, err := Verify(tok, &key.PublicKey); err != nil || len(claims) != 0 {
+		t.Fatalf("nil claims: %v, %v", claims, err)
+	}
+	if payload := strings.Split(tok, ".")[1]; payload != b64url([]byte("{}")) {
+		t.Fatalf("nil claims encoded as %q", payload)
+	}
+}
+
+func TestSign_AlgKeyMismatch(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	for alg, key := range map[string]any{"ES384": p256, "RS256": edKey, "HS256": p256, "none": edKey} {
+		if _, err := Sign(map[string]any{"alg": alg}, nil, key); err == nil {
+			t.Errorf("%s with %T: expected error", alg, key)
+		}
+	}
+}
+
+func TestThumbprint_RFC7638Example(t *testing.T) {
+	// RFC 7638 section 3.1.
+	data := `{"keys":[{"kty":"RSA","e":"AQAB","kid":"2011-04-29","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	keys, err := ParseJWKS([]byte(data))
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("ParseJWKS: %v %v", keys, err)
+	}
+	got, err := Thumbprint(keys[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Fatalf("thumbprint = %s, want %s", got, want)
+	}
+}
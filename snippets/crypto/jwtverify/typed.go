@@ -0,0 +1,160 @@
+package jwtverify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// VerifyInto verifies token like Verify and decodes its payload into a T,
+// typically a struct embedding RegisteredClaims:
+//
+//	type Claims struct {
+//		jwtverify.RegisteredClaims
+//		Scope string `json:"scope"`
+//	}
+//	claims, err := jwtverify.VerifyInto[Claims](token, key)
+//
+// Numbers decoded into interface values become json.Number rather than
+// float64, so large integer IDs keep their precision.
+func VerifyInto[T any](token string, key any) (T, error) {
+	var claims T
+	_, rawPayload, err := verifyCompact(token, key, parseOptions{})
+	if err != nil {
+		return claims, err
+	}
+	err = decodeNumbers(rawPayload, &claims)
+	return claims, err
+}
+
+func decodeNumbers(raw []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("jwt: parse payload json: %w", err)
+	}
+	return nil
+}
+
+// RegisteredClaims holds the registered claims of RFC 7519 section 4.1.
+// Embed it in your own claims struct and check it with
+// Validator.ValidateRegistered.
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+// Registered returns c. It is promoted to structs that embed
+// RegisteredClaims, so generic code can reach the registered claims.
+func (c *RegisteredClaims) Registered() *RegisteredClaims { return c }
+
+// ValidateRegistered is Validate for typed claims. Required may only name
+// registered claims.
+func (v *Validator) ValidateRegistered(c *RegisteredClaims) error {
+	m := map[string]any{}
+	set := func(name, s string) {
+		if s != "" {
+			m[name] = s
+		}
+	}
+	setDate := func(name string, d *NumericDate) {
+		if d != nil {
+			m[name] = json.Number(d.String())
+		}
+	}
+	set("iss", c.Issuer)
+	set("sub", c.Subject)
+	set("jti", c.ID)
+	if c.Audience != nil {
+		m["aud"] = []string(c.Audience)
+	}
+	setDate("exp", c.ExpiresAt)
+	setDate("nbf", c.NotBefore)
+	setDate("iat", c.IssuedAt)
+	return v.Validate(m)
+}
+
+// NumericDate is a JWT timestamp: seconds since the Unix epoch, possibly
+// fractional.
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate returns t as a NumericDate.
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t}
+}
+
+// String formats d as seconds, with a fraction only if needed.
+func (d NumericDate) String() string {
+	if d.Nanosecond() == 0 {
+		return strconv.FormatInt(d.Unix(), 10)
+	}
+	return strconv.FormatFloat(float64(d.UnixNano())/1e9, 'f', -1, 64)
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	// json.Number would also accept a quoted number; NumericDate must not.
+	if err := json.Unmarshal(b, &n); err != nil || bytes.HasPrefix(b, []byte(`"`)) {
+		return fmt.Errorf("%w: NumericDate must be a number", ErrInvalidClaim)
+	}
+	if i, err := n.Int64(); err == nil && i >= -maxNumericDate && i <= maxNumericDate {
+		d.Time = time.Unix(i, 0)
+		return nil
+	}
+	f, err := n.Float64()
+	t, ok := secondsToTime(f)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: NumericDate %s out of range", ErrInvalidClaim, n)
+	}
+	d.Time = t
+	return nil
+}
+
+// Audience is the "aud" claim, which may be encoded as a single string or an
+// array of strings.
+type Audience []string
+
+// Contains reports whether a includes aud.
+func (a Audience) Contains(aud string) bool {
+	return slices.Contains(a, aud)
+}
+
+// MarshalJSON encodes a single audience as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*a = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("%w: %q is not a string or array", ErrInvalidClaim, "aud")
+	}
+	*a = list
+	return nil
+}
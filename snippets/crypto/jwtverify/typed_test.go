@@ -0,0 +1,115 @@
+package jwtverify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	RegisteredClaims
+	Scope  string         `json:"scope"`
+	UserID int64          `json:"uid"`
+	Extra  map[string]any `json:"extra"`
+}
+
+func TestVerifyInto(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	tok, err := Sign(nil, map[string]any{
+		"iss":   "https://idp.example.com",
+		"aud":   "api",
+		"exp":   1700000000.5,
+		"iat":   1699990000,
+		"scope": "read",
+		"uid":   json.Number("9007199254740993"), // 2^53+1: not representable as float64
+		"extra": map[string]any{"big": json.Number("9007199254740993")},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := VerifyInto[testClaims](tok, key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Issuer != "https://idp.example.com" || !c.Audience.Contains("api") || c.Scope != "read" {
+		t.Fatalf("claims = %+v", c)
+	}
+	if want := time.Unix(1700000000, 5e8); !c.ExpiresAt.Equal(want) {
+		t.Fatalf("exp = %v, want %v", c.ExpiresAt.Time, want)
+	}
+	if c.IssuedAt.Unix() != 1699990000 {
+		t.Fatalf("iat = %v", c.IssuedAt.Time)
+	}
+	if c.UserID != 9007199254740993 || c.Extra["big"] != json.Number("9007199254740993") {
+		t.Fatalf("precision lost: uid=%d extra=%v", c.UserID, c.Extra["big"])
+	}
+
+	if _, err := VerifyInto[testClaims](tok, make(ed25519.PublicKey, ed25519.PublicKeySize)); err == nil {
+		t.Fatal("expected signature error")
+	}
+}
+
+func TestNumericDateJSON(t *testing.T) {
+	for in, ok := range map[string]bool{
+		`1700000000`:        true,
+		`1700000000.25`:     true,
+		`-1`:                true,
+		`1e300`:             false,
+		`-1e300`:            false,
+		`10000000000000000`: false, // beyond 2^53
+		`"1700000000"`:      false,
+		`null`:              false,
+	} {
+		var d NumericDate
+		err := json.Unmarshal([]byte(in), &d)
+		if ok != (err == nil) || err != nil && !errors.Is(err, ErrInvalidClaim) {
+			t.Errorf("%s: %v", in, err)
+		}
+	}
+
+	// A null pointer field is absent, which ValidateRegistered reports.
+	var c RegisteredClaims
+	if err := json.Unmarshal([]byte(`{"exp":null}`), &c); err != nil || c.ExpiresAt != nil {
+		t.Fatalf("null exp: %v %v", c.ExpiresAt, err)
+	}
+	if err := (&Validator{Required: []string{"exp"}}).ValidateRegistered(&c); !errors.Is(err, ErrMissingClaim) {
+		t.Fatalf("null exp validated: %v", err)
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	for in, want := range map[string]int{`"a"`: 1, `["a","b"]`: 2, `null`: 0} {
+		var a Audience
+		if err := json.Unmarshal([]byte(in), &a); err != nil || len(a) != want {
+			t.Errorf("%s: got %v, %v", in, a, err)
+		}
+	}
+	var a Audience
+	if err := json.Unmarshal([]byte(`[1]`), &a); !errors.Is(err, ErrInvalidClaim) {
+		t.Errorf("expected ErrInvalidClaim, got %v", err)
+	}
+	if b, _ := json.Marshal(Audience{"a"}); string(b) != `"a"` {
+		t.Errorf("single audience = %s", b)
+	}
+}
+
+func TestValidateRegistered(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v := &Validator{Audiences: []string{"api"}, Required: []string{"exp"}, Now: func() time.Time { return now }}
+	c := RegisteredClaims{Audience: Audience{"x", "api"}, ExpiresAt: NewNumericDate(now.Add(time.Minute))}
+	if err := v.ValidateRegistered(&c); err != nil {
+		t.Fatal(err)
+	}
+	c.ExpiresAt = NewNumericDate(now.Add(-time.Minute))
+	if err := v.ValidateRegistered(&c); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got %v, want ErrTokenExpired", err)
+	}
+	c.ExpiresAt = nil
+	if err := v.ValidateRegistered(&c); !errors.Is(err, ErrMissingClaim) {
+		t.Fatalf("got %v, want ErrMissingClaim", err)
+	}
+}
@@ -0,0 +1,295 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"-"`
+}
+
+// TokenSource returns tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenError is an error response from a token endpoint (RFC 6749 section 5.2).
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("httpclient: token endpoint: %s (%s)", e.Code, e.Description)
+	}
+	return fmt.Sprintf("httpclient: token endpoint: status %d %s", e.StatusCode, e.Code)
+}
+
+// ClientCredentials obtains tokens with the client_credentials grant.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams are extra form parameters (e.g. "audience").
+	EndpointParams url.Values
+	// AuthInBody sends client_id/client_secret as form fields instead of
+	// HTTP Basic auth.
+	AuthInBody bool
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	for k, vs := range c.EndpointParams {
+		form[k] = vs
+	}
+	return fetchToken(ctx, c.HTTPClient, c.TokenURL, c.ClientID, c.ClientSecret, c.AuthInBody, form)
+}
+
+// RefreshTokenSource obtains tokens with the refresh_token grant. If the server
+// rotates the refresh token, the new one is used for subsequent refreshes.
+type RefreshTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	AuthInBody   bool
+	HTTPClient   *http.Client
+
+	mu           sync.Mutex
+	refreshToken string
+}
+
+// NewRefreshTokenSource returns a RefreshTokenSource starting from refreshToken.
+func NewRefreshTokenSource(tokenURL, clientID, clientSecret, refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		refreshToken: refreshToken,
+	}
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshToken == "" {
+		return nil, errors.New("httpclient: no refresh token")
+	}
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.refreshToken}}
+	tok, err := fetchToken(ctx, s.HTTPClient, s.TokenURL, s.ClientID, s.ClientSecret, s.AuthInBody, form)
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken != "" {
+		s.refreshToken = tok.RefreshToken
+	}
+	return tok, nil
+}
+
+func fetchToken(ctx context.Context, hc *http.Client, tokenURL, id, secret string, inBody bool, form url.Values) (*Token, error) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	if inBody {
+		form.Set("client_id", id)
+		if secret != "" {
+			form.Set("client_secret", secret)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !inBody {
+		req.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: read token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		te := &TokenError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(body, te)
+		return nil, te
+	}
+	var tok Token
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("httpclient: parse token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("httpclient: token response has no access_token")
+	}
+	if tok.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return &tok, nil
+}
+
+// CachedTokenSource caches a token until shortly before it expires.
+//
+// Concurrent callers that find no valid token share a single fetch from the
+// underlying source. A caller whose context is canceled stops waiting, but the
+// shared fetch continues for the others.
+type CachedTokenSource struct {
+	src  TokenSource
+	skew time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	tok      *Token
+	inflight *tokenCall
+}
+
+type tokenCall struct {
+	done chan struct{}
+	tok  *Token
+	err  error
+}
+
+// NewCachedTokenSource wraps src. Tokens are refreshed skew before expiry;
+// if skew <= 0, it uses 30 seconds.
+func NewCachedTokenSource(src TokenSource, skew time.Duration) *CachedTokenSource {
+	if skew <= 0 {
+		skew = 30 * time.Second
+	}
+	return &CachedTokenSource{src: src, skew: skew, now: time.Now}
+}
+
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	if s.valid(s.tok) {
+		tok := s.tok
+		s.mu.Unlock()
+		return tok, nil
+	}
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		go s.fetch(context.WithoutCancel(ctx), call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.tok, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *CachedTokenSource) fetch(ctx context.Context, call *tokenCall) {
+	tok, err := s.src.Token(ctx)
+	s.mu.Lock()
+	if err == nil {
+		s.tok = tok
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+	call.tok, call.err = tok, err
+	close(call.done)
+}
+
+// Invalidate drops tok from the cache if it is still the cached token, forcing
+// the next Token call to fetch a new one.
+func (s *CachedTokenSource) Invalidate(tok *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == tok {
+		s.tok = nil
+	}
+}
+
+func (s *CachedTokenSource) valid(tok *Token) bool {
+	if tok == nil {
+		return false
+	}
+	return tok.Expiry.IsZero() || s.now().Add(s.skew).Before(tok.Expiry)
+}
+
+// AuthTransport adds "Authorization: Bearer <token>" to each request.
+//
+// If the server answers 401 and Source is a *CachedTokenSource, the token is
+// invalidated and the request is retried once with a fresh token (requests
+// with a non-rewindable body are not retried).
+type AuthTransport struct {
+	Base   http.RoundTripper
+	Source TokenSource
+}
+
+// NewAuthTransport returns an AuthTransport with a cached token source.
+func NewAuthTransport(base http.RoundTripper, src TokenSource) *AuthTransport {
+	if _, ok := src.(*CachedTokenSource); !ok {
+		src = NewCachedTokenSource(src, 0)
+	}
+	return &AuthTransport{Base: base, Source: src}
+}
+
+func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, tok, err := t.send(req)
+	if err != nil {
+		return nil, err
+	}
+	cached, ok := t.Source.(*CachedTokenSource)
+	if resp.StatusCode != http.StatusUnauthorized || !ok {
+		return resp, nil
+	}
+	retry, err := rewindBody(req)
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	cached.Invalidate(tok)
+	resp, _, err = t.send(retry)
+	return resp, err
+}
+
+func (t *AuthTransport) send(req *http.Request) (*http.Response, *Token, error) {
+	tok, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	r := req.Clone(req.Context())
+	typ := tok.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	r.Header.Set("Authorization", typ+" "+tok.AccessToken)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	return resp, tok, err
+}
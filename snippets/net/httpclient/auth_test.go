@@ -0,0 +1,88 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthTransport_ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "svc" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		time.Sleep(20 * time.Millisecond) // widen the window for a stampede
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+
+	// The API rejects the first token to exercise the 401 retry.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer tok-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer api.Close()
+
+	src := &ClientCredentials{TokenURL: tokenSrv.URL, ClientID: "svc", ClientSecret: "s3cret"}
+	c := New(Options{Transport: NewAuthTransport(nil, src)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, api.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 200 {
+				t.Errorf("status %d", resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Fatalf("expected 2 token requests (initial + after 401), got %d", n)
+	}
+}
+
+func TestRefreshTokenSource_Rotates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rt := r.FormValue("refresh_token")
+		fmt.Fprintf(w, `{"access_token":"at-%s","refresh_token":"%s+","expires_in":60}`, rt, rt)
+	}))
+	defer srv.Close()
+
+	src := NewRefreshTokenSource(srv.URL, "app", "", "r0")
+	src.AuthInBody = true
+	for _, want := range []string{"at-r0", "at-r0+"} {
+		tok, err := src.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.AccessToken != want {
+			t.Fatalf("got %q, want %q", tok.AccessToken, want)
+		}
+	}
+}
@@ -0,0 +1,585 @@
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/io/atomicfile"
+)
+
+// XFromCache is set to "1" on responses served from the cache.
+const XFromCache = "X-From-Cache"
+
+// CacheEntry is a stored response.
+type CacheEntry struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// RequestTime and ResponseTime bracket the exchange that produced (or last
+	// revalidated) the entry. They are used for Age calculation.
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+	// Vary holds the request header values selected by the response's Vary header.
+	Vary map[string]string `json:"vary,omitempty"`
+	// Variants lists the keys of the URL's stored variants (on the entry under
+	// the plain key only), so invalidation can remove them all.
+	Variants []string `json:"variants,omitempty"`
+}
+
+// CacheStore stores entries by key. Implementations must be safe for concurrent
+// use. Caching is best-effort, so stores report no errors; a failing store just
+// behaves like a miss.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, e *CacheEntry)
+	Delete(key string)
+}
+
+// CacheTransport is an http.RoundTripper implementing a private HTTP cache
+// (RFC 9111) for GET requests.
+//
+// It honors Cache-Control max-age, no-store, no-cache, must-revalidate,
+// stale-while-revalidate and stale-if-error, falls back to Expires and the
+// Last-Modified heuristic, revalidates with If-None-Match/If-Modified-Since,
+// and matches stored variants on the response's Vary header. Successful unsafe
+// requests (POST, PUT, PATCH, DELETE) invalidate the entries for their URL.
+//
+// Entries are keyed by URL, so responses to requests carrying Authorization or
+// Cookie are only stored when marked public, s-maxage or must-revalidate (RFC
+// 9111 section 3.5); otherwise callers sharing the transport could see each
+// other's data.
+type CacheTransport struct {
+	Base  http.RoundTripper
+	Store CacheStore
+	// MaxBodyBytes limits the size of cached bodies. Larger responses are
+	// passed through. Defaults to 10 MiB.
+	MaxBodyBytes int64
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu         sync.Mutex // guards revalidate and the plain entries' Variants
+	revalidate map[string]bool
+}
+
+// NewCacheTransport returns a CacheTransport on top of base (nil means
+// http.DefaultTransport).
+func NewCacheTransport(base http.RoundTripper, store CacheStore) *CacheTransport {
+	return &CacheTransport{Base: base, Store: store}
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	if req.Method != http.MethodGet {
+		resp, err := t.base().RoundTrip(req)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			t.invalidate(key)
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	_, noStore := reqCC["no-store"]
+	// Caller-supplied conditionals must reach the origin so the caller sees
+	// its own 304, and range requests its own 206.
+	if noStore || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" ||
+		req.Header.Get("Range") != "" || req.Header.Get("If-Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	now := t.now()
+	entry, ok := t.lookup(key, req)
+	if ok {
+		age := entry.age(now)
+		lifetime := entry.freshnessLifetime()
+		respCC := parseCacheControl(entry.Header)
+		_, reqNoCache := reqCC["no-cache"]
+		_, respNoCache := respCC["no-cache"]
+		_, mustRevalidate := respCC["must-revalidate"]
+		maxAge, hasMaxAge := ccSeconds(reqCC, "max-age")
+
+		fresh := age < lifetime && !reqNoCache && !respNoCache && (!hasMaxAge || age <= maxAge)
+		if fresh {
+			return entry.response(req, age), nil
+		}
+		if swr, ok := ccSeconds(respCC, "stale-while-revalidate"); ok &&
+			!reqNoCache && !respNoCache && !mustRevalidate && age < lifetime+swr {
+			t.revalidateAsync(req, key, entry)
+			return entry.response(req, age), nil
+		}
+	}
+
+	resp, err := t.fetch(req, key, entry, ok)
+	if ok && (err != nil || resp.StatusCode >= 500) && entry.staleIfError(reqCC, now) {
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		return entry.response(req, entry.age(now)), nil
+	}
+	return resp, err
+}
+
+// fetch sends req (conditionally, if a stored entry has validators) and updates
+// the store from the response.
+func (t *CacheTransport) fetch(req *http.Request, key string, entry *CacheEntry, haveEntry bool) (*http.Response, error) {
+	out := req
+	if haveEntry {
+		etag, lastMod := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastMod != "" {
+			out = req.Clone(req.Context())
+			if etag != "" {
+				out.Header.Set("If-None-Match", etag)
+			}
+			if lastMod != "" {
+				out.Header.Set("If-Modified-Since", lastMod)
+			}
+		}
+	}
+
+	reqTime := t.now()
+	resp, err := t.base().RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respTime := t.now()
+
+	if haveEntry && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for k, vs := range resp.Header {
+			if k == "Content-Length" {
+				continue
+			}
+			updated.Header[k] = vs
+		}
+		updated.RequestTime, updated.ResponseTime = reqTime, respTime
+		t.store(key, &updated)
+		return updated.response(req, updated.age(respTime)), nil
+	}
+
+	if !isCacheable(req, resp) {
+		return resp, nil
+	}
+	limit := t.maxBodyBytes()
+	if resp.ContentLength > limit {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		// Too large to cache: hand back what we read followed by the rest.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.store(key, &CacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  reqTime,
+		ResponseTime: respTime,
+		Vary:         varyValues(resp.Header, req),
+	})
+	return resp, nil
+}
+
+// lookup returns the stored entry matching req. The entry under the plain key
+// is the most recent response for the URL; if it varies and does not match
+// req, the variant stored under a Vary-specific key is tried.
+func (t *CacheTransport) lookup(key string, req *http.Request) (*CacheEntry, bool) {
+	e, ok := t.Store.Get(key)
+	if !ok || varyMatches(e, req) {
+		return e, ok
+	}
+	e, ok = t.Store.Get(variantKey(key, e.Vary, req))
+	if !ok || !varyMatches(e, req) {
+		return nil, false
+	}
+	return e, true
+}
+
+// store saves e as the most recent response for key and, if it varies, as
+// its variant.
+func (t *CacheTransport) store(key string, e *CacheEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	plain := *e
+	plain.Variants = nil
+	if prev, ok := t.Store.Get(key); ok {
+		plain.Variants = prev.Variants
+	}
+	if len(e.Vary) > 0 {
+		vk := variantKey(key, e.Vary, nil)
+		variant := *e
+		variant.Variants = nil
+		t.Store.Set(vk, &variant)
+		if !slices.Contains(plain.Variants, vk) {
+			plain.Variants = append(slices.Clone(plain.Variants), vk)
+		}
+	}
+	t.Store.Set(key, &plain)
+}
+
+// invalidate removes key and all of its variants.
+func (t *CacheTransport) invalidate(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.Store.Get(key); ok {
+		for _, vk := range e.Variants {
+			t.Store.Delete(vk)
+		}
+	}
+	t.Store.Delete(key)
+}
+
+// variantKey derives a key from the header names in vary and their values in
+// req (or the stored values when req is nil).
+func variantKey(key string, vary map[string]string, req *http.Request) string {
+	names := make([]string, 0, len(vary))
+	for name := range vary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range names {
+		v := vary[name]
+		if req != nil {
+			v = strings.Join(req.Header.Values(name), ",")
+		}
+		b.WriteString("\x00" + name + "=" + v)
+	}
+	return b.String()
+}
+
+func (t *CacheTransport) revalidateAsync(req *http.Request, key string, entry *CacheEntry) {
+	t.mu.Lock()
+	if t.revalidate == nil {
+		t.revalidate = make(map[string]bool)
+	}
+	if t.revalidate[key] {
+		t.mu.Unlock()
+		return
+	}
+	t.revalidate[key] = true
+	t.mu.Unlock()
+
+	bg := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.revalidate, key)
+			t.mu.Unlock()
+		}()
+		resp, err := t.fetch(bg, key, entry, true)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+}
+
+func (t *CacheTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *CacheTransport) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+func (t *CacheTransport) maxBodyBytes() int64 {
+	if t.MaxBodyBytes > 0 {
+		return t.MaxBodyBytes
+	}
+	return 10 << 20
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+func isUnsafeMethod(m string) bool {
+	switch m {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// heuristicallyCacheable lists status codes that may be cached without
+// explicit freshness information (RFC 9110 section 15.1).
+var heuristicallyCacheable = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+func isCacheable(req *http.Request, resp *http.Response) bool {
+	// Partial content would be served as the whole body.
+	if req.Method != http.MethodGet || resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		_, public := cc["public"]
+		_, sMaxAge := cc["s-maxage"]
+		_, mustRevalidate := cc["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return false
+		}
+	}
+	_, hasMaxAge := cc["max-age"]
+	explicit := hasMaxAge || resp.Header.Get("Expires") != ""
+	if !heuristicallyCacheable[resp.StatusCode] && !explicit {
+		return false
+	}
+	// Worth storing if it can be served fresh or revalidated later.
+	return explicit || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func (e *CacheEntry) response(req *http.Request, age time.Duration) *http.Response {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	h.Set(XFromCache, "1")
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// age computes the current age per RFC 9111 section 4.2.3.
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	var apparent time.Duration
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		apparent = max(0, e.ResponseTime.Sub(date))
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil {
+		ageValue = time.Duration(n) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+// freshnessLifetime implements RFC 9111 section 4.2.1 for a private cache.
+func (e *CacheEntry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if d, ok := ccSeconds(cc, "max-age"); ok {
+		return d
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.ResponseTime
+	}
+	if exp := e.Header.Get("Expires"); exp != "" {
+		t, err := http.ParseTime(exp)
+		if err != nil {
+			return 0 // invalid Expires means already expired
+		}
+		return max(0, t.Sub(date))
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable[e.StatusCode] {
+		return max(0, date.Sub(lm)/10)
+	}
+	return 0
+}
+
+func (e *CacheEntry) staleIfError(reqCC cacheControl, now time.Time) bool {
+	d, ok := ccSeconds(parseCacheControl(e.Header), "stale-if-error")
+	if rd, rok := ccSeconds(reqCC, "stale-if-error"); rok {
+		d, ok = rd, true
+	}
+	return ok && e.age(now) < e.freshnessLifetime()+d
+}
+
+func varyValues(h http.Header, req *http.Request) map[string]string {
+	var out map[string]string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]string)
+			}
+			out[name] = strings.Join(req.Header.Values(name), ",")
+		}
+	}
+	return out
+}
+
+func varyMatches(e *CacheEntry, req *http.Request) bool {
+	for name, want := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != want {
+			return false
+		}
+	}
+	return true
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, val, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return cc
+}
+
+func ccSeconds(cc cacheControl, name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// MemoryCache is an in-memory CacheStore with LRU eviction.
+type MemoryCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns a MemoryCache holding at most maxEntries entries.
+// If maxEntries <= 0, it uses 1000.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryCache{max: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*memoryCacheItem).entry, true
+}
+
+func (c *MemoryCache) Set(key string, e *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*memoryCacheItem).entry = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&memoryCacheItem{key: key, entry: e})
+	for c.ll.Len() > c.max {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// DiskCache is a CacheStore keeping one JSON file per entry in Dir.
+//
+// Files are written with atomicfile, so concurrent readers (including other
+// processes) never observe partial entries.
+type DiskCache struct {
+	Dir string
+}
+
+func (c DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c DiskCache) Get(key string) (*CacheEntry, bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e CacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (c DiskCache) Set(key string, e *CacheEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = atomicfile.WriteFile(c.path(key), b, 0o600)
+}
+
+func (c DiskCache) Delete(key string) {
+	_ = os.Remove(c.path(key))
+}
@@ -0,0 +1,256 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func getBody(t *testing.T, c *Client, url string, hdr http.Header) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestCache_MaxAgeAndRevalidate(t *testing.T) {
+	var hits, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("config"))
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{t: time.Now()}
+	ct := NewCacheTransport(nil, NewMemoryCache(10))
+	ct.Now = clock.Now
+	c := New(Options{Transport: ct})
+
+	getBody(t, c, srv.URL, nil)
+	resp, body := getBody(t, c, srv.URL, nil)
+	if body != "config" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("expected cached body, got %q (from cache %q)", body, resp.Header.Get(XFromCache))
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("expected 1 upstream hit, got %d", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	_, body = getBody(t, c, srv.URL, nil)
+	if body != "config" || atomic.LoadInt32(&notModified) != 1 {
+		t.Fatalf("expected revalidation via 304, body %q, 304s %d", body, notModified)
+	}
+}
+
+func TestCache_StaleIfErrorAndVary(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-if-error=300")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{t: time.Now()}
+	ct := NewCacheTransport(nil, DiskCache{Dir: t.TempDir()})
+	ct.Now = clock.Now
+	c := New(Options{Transport: ct})
+
+	en := http.Header{"Accept-Language": {"en"}}
+	getBody(t, c, srv.URL, en)
+
+	// A different variant is a miss.
+	resp, body := getBody(t, c, srv.URL, http.Header{"Accept-Language": {"fr"}})
+	if body != "hello fr" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("vary: got %q from cache=%q", body, resp.Header.Get(XFromCache))
+	}
+
+	clock.Advance(time.Minute)
+	fail.Store(true)
+	resp, body = getBody(t, c, srv.URL, en)
+	if resp.StatusCode != 200 || body != "hello en" {
+		t.Fatalf("stale-if-error: got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		w.Header()["Date"] = nil // ages then follow the fake clock
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		fmt.Fprintf(w, "v%d", n)
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{t: time.Now()}
+	ct := NewCacheTransport(nil, NewMemoryCache(10))
+	ct.Now = clock.Now
+	c := New(Options{Transport: ct})
+
+	getBody(t, c, srv.URL, nil)
+	clock.Advance(30 * time.Second)
+
+	// Stale but within the window: served at once, refreshed in the background.
+	resp, body := getBody(t, c, srv.URL, nil)
+	if body != "v1" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("stale response: %q from cache=%q", body, resp.Header.Get(XFromCache))
+	}
+	// Wait for the background fetch to finish before touching the clock.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ct.mu.Lock()
+		pending := len(ct.revalidate)
+		ct.mu.Unlock()
+		if pending == 0 && hits.Load() == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background revalidation did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp, body = getBody(t, c, srv.URL, nil); body != "v2" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("after revalidation: %q from cache=%q", body, resp.Header.Get(XFromCache))
+	}
+
+	// Past the window the request waits for the origin.
+	clock.Advance(2 * time.Minute)
+	if resp, body = getBody(t, c, srv.URL, nil); body != "v3" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("after window: %q from cache=%q", body, resp.Header.Get(XFromCache))
+	}
+}
+
+func TestCache_RangeBypass(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	c := New(Options{Transport: NewCacheTransport(nil, NewMemoryCache(10))})
+
+	// A 206 is never stored, so it cannot be served as the full body.
+	resp, body := getBody(t, c, srv.URL, http.Header{"Range": {"bytes=0-3"}})
+	if resp.StatusCode != http.StatusPartialContent || body != "0123" {
+		t.Fatalf("range: %d %q", resp.StatusCode, body)
+	}
+	if resp, body = getBody(t, c, srv.URL, nil); resp.StatusCode != 200 || body != "0123456789" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("full after range: %d %q from cache=%q", resp.StatusCode, body, resp.Header.Get(XFromCache))
+	}
+
+	// With a full response cached, range requests still reach the origin.
+	resp, body = getBody(t, c, srv.URL, http.Header{"Range": {"bytes=4-5"}, "If-Range": {`"v1"`}})
+	if resp.StatusCode != http.StatusPartialContent || body != "45" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("range after full: %d %q from cache=%q", resp.StatusCode, body, resp.Header.Get(XFromCache))
+	}
+	if n := hits.Load(); n != 3 {
+		t.Fatalf("expected 3 upstream hits, got %d", n)
+	}
+}
+
+func TestCache_Credentials(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("data for " + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := New(Options{Transport: NewCacheTransport(nil, NewMemoryCache(10))})
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"Authorization": {"Bearer bob"}}
+
+	getBody(t, c, srv.URL+"/me", alice)
+	if resp, body := getBody(t, c, srv.URL+"/me", bob); body != "data for Bearer bob" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("credentialed response shared: %q from cache=%q", body, resp.Header.Get(XFromCache))
+	}
+	if _, body := getBody(t, c, srv.URL+"/me", http.Header{"Cookie": {"session=x"}}); body != "data for " {
+		t.Fatalf("cookie request served %q", body)
+	}
+
+	// Responses marked public may be shared.
+	getBody(t, c, srv.URL+"/public", alice)
+	if resp, _ := getBody(t, c, srv.URL+"/public", bob); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("public response not cached")
+	}
+	if n := hits.Load(); n != 4 {
+		t.Fatalf("expected 4 upstream hits, got %d", n)
+	}
+}
+
+func TestCache_InvalidateVariants(t *testing.T) {
+	var version atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			version.Add(1)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "v%d %s", version.Load(), r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+
+	c := New(Options{Transport: NewCacheTransport(nil, NewMemoryCache(10))})
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	getBody(t, c, srv.URL, en)
+	getBody(t, c, srv.URL, fr)
+	if resp, _ := getBody(t, c, srv.URL, en); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("en variant not cached")
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, strings.NewReader("x"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Refill the plain entry with one variant; the other must not be stale.
+	if _, body := getBody(t, c, srv.URL, fr); body != "v1 fr" {
+		t.Fatalf("fr after POST: %q", body)
+	}
+	if _, body := getBody(t, c, srv.URL, en); body != "v1 en" {
+		t.Fatalf("en after POST: %q", body)
+	}
+}
@@ -0,0 +1,373 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/shijianliangs/golang-snippets/snippets/io/atomicfile"
+)
+
+// CassetteMode selects whether a Recorder talks to the network.
+type CassetteMode int
+
+const (
+	// ModeReplay serves responses from the cassette and never touches the
+	// network. Unmatched requests fail with *UnmatchedRequestError.
+	ModeReplay CassetteMode = iota
+	// ModeRecord sends requests to the real server and records the exchanges.
+	ModeRecord
+)
+
+// Redacted replaces redacted header values in cassettes.
+const Redacted = "REDACTED"
+
+// Cassette is the on-disk format of a recording.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the request half of an Interaction.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	// Body holds the raw bytes. In the file it is a JSON string when valid
+	// UTF-8 and base64 (with "body_encoding": "base64") otherwise.
+	Body string `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of an Interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	// Body is encoded as for RecordedRequest.Body.
+	Body string `json:"body,omitempty"`
+}
+
+// bodyEncodingBase64 marks a body stored base64-encoded because it is not
+// valid UTF-8, which a JSON string cannot carry byte for byte.
+const bodyEncodingBase64 = "base64"
+
+func (rr RecordedRequest) MarshalJSON() ([]byte, error) {
+	type plain RecordedRequest
+	body, enc := encodeBody(rr.Body)
+	rr.Body = body
+	return json.Marshal(struct {
+		plain
+		BodyEncoding string `json:"body_encoding,omitempty"`
+	}{plain(rr), enc})
+}
+
+func (rr *RecordedRequest) UnmarshalJSON(b []byte) error {
+	type plain RecordedRequest
+	var v struct {
+		plain
+		BodyEncoding string `json:"body_encoding"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*rr = RecordedRequest(v.plain)
+	var err error
+	rr.Body, err = decodeBody(rr.Body, v.BodyEncoding)
+	return err
+}
+
+func (rr RecordedResponse) MarshalJSON() ([]byte, error) {
+	type plain RecordedResponse
+	body, enc := encodeBody(rr.Body)
+	rr.Body = body
+	return json.Marshal(struct {
+		plain
+		BodyEncoding string `json:"body_encoding,omitempty"`
+	}{plain(rr), enc})
+}
+
+func (rr *RecordedResponse) UnmarshalJSON(b []byte) error {
+	type plain RecordedResponse
+	var v struct {
+		plain
+		BodyEncoding string `json:"body_encoding"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*rr = RecordedResponse(v.plain)
+	var err error
+	rr.Body, err = decodeBody(rr.Body, v.BodyEncoding)
+	return err
+}
+
+func encodeBody(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(body)), bodyEncodingBase64
+}
+
+func decodeBody(body, enc string) (string, error) {
+	switch enc {
+	case "":
+		return body, nil
+	case bodyEncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return "", fmt.Errorf("httpclient: decode cassette body: %w", err)
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("httpclient: unknown cassette body encoding %q", enc)
+}
+
+// Matcher reports whether a live request (with its already-redacted body)
+// matches a recorded one.
+type Matcher func(req *http.Request, body string, rec RecordedRequest) bool
+
+// MatchMethod matches on the HTTP method.
+func MatchMethod(req *http.Request, _ string, rec RecordedRequest) bool {
+	return req.Method == rec.Method
+}
+
+// MatchURL matches on the full URL, including the query string.
+func MatchURL(req *http.Request, _ string, rec RecordedRequest) bool {
+	return req.URL.String() == rec.URL
+}
+
+// MatchBody matches on the (redacted) request body.
+func MatchBody(_ *http.Request, body string, rec RecordedRequest) bool {
+	return body == rec.Body
+}
+
+// MatchHeaders returns a Matcher comparing the given request headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, _ string, rec RecordedRequest) bool {
+		for _, n := range names {
+			if strings.Join(req.Header.Values(n), ",") != strings.Join(rec.Header.Values(n), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// BodyRedaction replaces every match of Pattern in recorded bodies.
+type BodyRedaction struct {
+	Pattern *regexp.Regexp
+	Replace string
+}
+
+// UnmatchedRequestError is returned in replay mode when no recorded
+// interaction matches a request. Client does not retry it.
+type UnmatchedRequestError struct {
+	Method string
+	URL    string
+	Body   string
+	Path   string
+}
+
+func (e *UnmatchedRequestError) Error() string {
+	return fmt.Sprintf("httpclient: cassette %s has no unused interaction for %s %s (body %q)", e.Path, e.Method, e.URL, e.Body)
+}
+
+// Recorder is an http.RoundTripper that records exchanges to, or replays them
+// from, a JSON cassette file.
+//
+// Each recorded interaction is replayed at most once, in recording order, so
+// tests that issue the same request several times see the responses in the
+// order they were recorded.
+type Recorder struct {
+	Path string
+	Mode CassetteMode
+	// Base is used in record mode; nil means http.DefaultTransport.
+	Base http.RoundTripper
+	// Matchers decide whether a recorded request matches; all must agree.
+	// Defaults to MatchMethod, MatchURL and MatchBody.
+	Matchers []Matcher
+	// RedactHeaders lists request and response headers whose values are
+	// replaced with Redacted before saving. Authorization,
+	// Proxy-Authorization, Cookie and Set-Cookie are always redacted.
+	RedactHeaders []string
+	// RedactBody is applied to request and response bodies before saving and
+	// to live request bodies before matching.
+	RedactBody []BodyRedaction
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder creates a Recorder. In replay mode the cassette at path is loaded
+// and must exist.
+func NewRecorder(path string, mode CassetteMode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: load cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("httpclient: parse cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+	}
+	if r.Mode == ModeReplay {
+		return r.replay(req, r.redactBody(string(reqBody)))
+	}
+	return r.record(req, reqBody)
+}
+
+func (r *Recorder) replay(req *http.Request, body string) (*http.Response, error) {
+	matchers := r.Matchers
+	if len(matchers) == 0 {
+		matchers = []Matcher{MatchMethod, MatchURL, MatchBody}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+next:
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		for _, m := range matchers {
+			if !m(req, body, in.Request) {
+				continue next
+			}
+		}
+		r.used[i] = true
+		return in.Response.toHTTP(req), nil
+	}
+	return nil, &UnmatchedRequestError{Method: req.Method, URL: req.URL.String(), Body: body, Path: r.Path}
+}
+
+func (r *Recorder) record(req *http.Request, reqBody []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if reqBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(reqBody))
+		out.ContentLength = int64(len(reqBody))
+	}
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redactHeader(req.Header),
+			Body:   r.redactBody(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       r.redactBody(string(respBody)),
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save writes the recorded interactions to Path atomically. It is a no-op in
+// replay mode.
+func (r *Recorder) Save() error {
+	if r.Mode == ModeReplay {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(r.Path, append(b, '\n'), 0o644)
+}
+
+// Unused returns the recorded interactions that were never replayed, which
+// usually means the code under test stopped making a call.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Interaction
+	for i, in := range r.cassette.Interactions {
+		if i < len(r.used) && !r.used[i] {
+			out = append(out, in)
+		}
+	}
+	return out
+}
+
+var alwaysRedacted = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, names := range [][]string{alwaysRedacted, r.RedactHeaders} {
+		for _, n := range names {
+			if vs := out.Values(n); len(vs) > 0 {
+				out[http.CanonicalHeaderKey(n)] = []string{Redacted}
+			}
+		}
+	}
+	return out
+}
+
+func (r *Recorder) redactBody(s string) string {
+	for _, rd := range r.RedactBody {
+		s = rd.Pattern.ReplaceAllString(s, rd.Replace)
+	}
+	return s
+}
+
+func (rr RecordedResponse) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rr.StatusCode, http.StatusText(rr.StatusCode)),
+		StatusCode:    rr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rr.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
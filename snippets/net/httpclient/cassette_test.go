@@ -0,0 +1,122 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_RecordThenReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write([]byte("echo:" + string(b)))
+	}))
+	path := filepath.Join(t.TempDir(), "testdata", "echo.json")
+
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.RedactBody = []BodyRedaction{{Pattern: regexp.MustCompile(`"password":"[^"]*"`), Replace: `"password":"x"`}}
+	post := func(c *Client, body string) (string, error) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/login", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret-token")
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), nil
+	}
+	if _, err := post(New(Options{Transport: rec}), `{"password":"hunter2"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "hunter2") || strings.Contains(string(raw), "secret-token") {
+		t.Fatalf("cassette leaks secrets:\n%s", raw)
+	}
+
+	replay, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.RedactBody = rec.RedactBody
+	c := New(Options{Transport: replay})
+	got, err := post(c, `{"password":"other"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `echo:{"password":"x"}` {
+		t.Fatalf("replayed body %q", got)
+	}
+
+	// A miss fails at once instead of being retried.
+	var retries int
+	c = New(Options{Transport: replay, MaxRetries: 3, Hooks: Hooks{
+		OnRetry: func(*http.Request, int, time.Duration, error) { retries++ },
+	}})
+	_, err = post(c, `{"user":"bob"}`)
+	var ue *UnmatchedRequestError
+	if !errors.As(err, &ue) {
+		t.Fatalf("expected UnmatchedRequestError, got %v", err)
+	}
+	if retries != 0 {
+		t.Fatalf("unmatched request retried %d times", retries)
+	}
+}
+
+func TestRecorder_BinaryBody(t *testing.T) {
+	payload := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff, 0xfe, 'a', 0x80, 0x00}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(append(b, payload...))
+	}))
+	path := filepath.Join(t.TempDir(), "binary.json")
+
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(c *Client) []byte {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPut, srv.URL, bytes.NewReader(payload))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return b
+	}
+	want := send(New(Options{Transport: rec}))
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	replay, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := send(New(Options{Transport: replay})); !bytes.Equal(got, want) {
+		t.Fatalf("replayed %x, want %x", got, want)
+	}
+	if raw, _ := os.ReadFile(path); !strings.Contains(string(raw), `"body_encoding": "base64"`) {
+		t.Fatalf("binary body not base64-encoded:\n%s", raw)
+	}
+}
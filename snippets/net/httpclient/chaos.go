@@ -0,0 +1,243 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FaultKind is the kind of fault a ChaosTransport injects.
+type FaultKind int
+
+const (
+	// FaultNone passes the request through untouched.
+	FaultNone FaultKind = iota
+	// FaultReset fails the request with ECONNRESET without contacting the server.
+	FaultReset
+	// FaultStatus answers with Fault.Status without contacting the server.
+	FaultStatus
+	// FaultLatency waits Fault.Delay before sending the request.
+	FaultLatency
+	// FaultSlowHeaders waits Fault.Delay after the server responded, before
+	// returning the headers. Client timeouts fire during the wait.
+	FaultSlowHeaders
+	// FaultTruncate cuts the response body after Fault.Bytes bytes with
+	// io.ErrUnexpectedEOF.
+	FaultTruncate
+)
+
+// Fault describes one injected failure.
+type Fault struct {
+	Kind   FaultKind
+	Status int
+	Delay  time.Duration
+	Bytes  int64
+}
+
+// ChaosRule injects Fault with the given probability (0..1).
+type ChaosRule struct {
+	Probability float64
+	Fault       Fault
+}
+
+// ChaosTransport is an http.RoundTripper that injects faults, for exercising
+// retry and timeout handling.
+//
+// Requests first consume Script, one fault per request in order (for example
+// "fail the first 2 attempts, then succeed"). Once the script is exhausted,
+// each rule in Rules is rolled in order and the first hit is injected.
+type ChaosTransport struct {
+	Base   http.RoundTripper
+	Script []Fault
+	Rules  []ChaosRule
+	// Rand is the randomness source for Rules. Defaults to a time-seeded source.
+	Rand *rand.Rand
+
+	mu sync.Mutex
+	n  int
+}
+
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := t.next()
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	switch f.Kind {
+	case FaultReset:
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case FaultStatus:
+		closeBody(req)
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+			StatusCode: f.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("chaos: injected status\n")),
+			Request:    req,
+		}, nil
+	case FaultLatency:
+		if err := sleepCtx(req.Context(), f.Delay); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+		return base.RoundTrip(req)
+	case FaultSlowHeaders:
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := sleepCtx(req.Context(), f.Delay); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, nil
+	case FaultTruncate:
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = &truncatedBody{rc: resp.Body, left: f.Bytes}
+		resp.ContentLength = -1
+		return resp, nil
+	default:
+		return base.RoundTrip(req)
+	}
+}
+
+func (t *ChaosTransport) next() Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.n
+	t.n++
+	if i < len(t.Script) {
+		return t.Script[i]
+	}
+	if t.Rand == nil && len(t.Rules) > 0 {
+		t.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	for _, r := range t.Rules {
+		if t.Rand.Float64() < r.Probability {
+			return r.Fault
+		}
+	}
+	return Fault{}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+type truncatedBody struct {
+	rc   io.ReadCloser
+	left int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.rc.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func (b *truncatedBody) Close() error { return b.rc.Close() }
+
+// ParseChaos parses a comma-separated fault spec, for configuring chaos from a
+// flag or environment variable in staging builds.
+//
+// Each item is a fault followed by an optional "*N" repeat count (added to the
+// script) or "@P" probability (added to the rules):
+//
+//	ok               pass through
+//	reset            ECONNRESET
+//	503              synthetic status (any 3-digit code)
+//	latency=200ms    delay before sending
+//	slow=2s          delay before returning headers
+//	truncate=100     cut the body after 100 bytes
+//
+// For example "reset*2,ok" fails the first two requests and then succeeds, and
+// "503@0.05,latency=1s@0.1" adds random 503s and latency spikes.
+func ParseChaos(spec string) (script []Fault, rules []ChaosRule, err error) {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, count, prob := item, 1, -1.0
+		if i := strings.LastIndexAny(item, "*@"); i >= 0 {
+			name = item[:i]
+			arg := item[i+1:]
+			if item[i] == '*' {
+				count, err = strconv.Atoi(arg)
+				if err != nil || count < 1 {
+					return nil, nil, fmt.Errorf("httpclient: chaos %q: bad repeat count", item)
+				}
+			} else {
+				prob, err = strconv.ParseFloat(arg, 64)
+				if err != nil || prob < 0 || prob > 1 {
+					return nil, nil, fmt.Errorf("httpclient: chaos %q: bad probability", item)
+				}
+			}
+		}
+		f, err := parseFault(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if prob >= 0 {
+			rules = append(rules, ChaosRule{Probability: prob, Fault: f})
+			continue
+		}
+		for i := 0; i < count; i++ {
+			script = append(script, f)
+		}
+	}
+	return script, rules, nil
+}
+
+func parseFault(s string) (Fault, error) {
+	name, arg, _ := strings.Cut(s, "=")
+	switch name {
+	case "ok":
+		return Fault{}, nil
+	case "reset":
+		return Fault{Kind: FaultReset}, nil
+	case "latency", "slow":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return Fault{}, fmt.Errorf("httpclient: chaos %q: %w", s, err)
+		}
+		if name == "latency" {
+			return Fault{Kind: FaultLatency, Delay: d}, nil
+		}
+		return Fault{Kind: FaultSlowHeaders, Delay: d}, nil
+	case "truncate":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n < 0 {
+			return Fault{}, fmt.Errorf("httpclient: chaos %q: bad byte count", s)
+		}
+		return Fault{Kind: FaultTruncate, Bytes: n}, nil
+	}
+	if code, err := strconv.Atoi(name); err == nil && code >= 100 && code <= 599 {
+		return Fault{Kind: FaultStatus, Status: code}, nil
+	}
+	return Fault{}, fmt.Errorf("httpclient: chaos: unknown fault %q", s)
+}
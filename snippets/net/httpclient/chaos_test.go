@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChaos_ScriptedResetsAreRetried(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	script, rules, err := ParseChaos("reset*2,503,ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := &ChaosTransport{Script: script, Rules: rules}
+	c := New(Options{MaxRetries: 3, BaseBackoff: time.Millisecond, Transport: ct})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("expected success after faults, got %v", err)
+	}
+	resp.Body.Close()
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("expected only the last attempt to reach the server, got %d", n)
+	}
+}
+
+func TestChaos_SlowHeadersAndTruncate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	ct := &ChaosTransport{Script: []Fault{
+		{Kind: FaultSlowHeaders, Delay: time.Second},
+		{Kind: FaultTruncate, Bytes: 4},
+	}}
+	c := New(Options{Timeout: 50 * time.Millisecond, Transport: ct})
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected timeout from slow headers")
+	}
+
+	req, _ = http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || string(b) != "0123" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+}
+
+func TestParseChaos_Rules(t *testing.T) {
+	script, rules, err := ParseChaos("503@0.5, latency=10ms@1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script) != 0 || len(rules) != 2 || rules[1].Fault.Kind != FaultLatency || rules[1].Fault.Delay != 10*time.Millisecond {
+		t.Fatalf("script %+v rules %+v", script, rules)
+	}
+	if _, _, err := ParseChaos("explode"); err == nil {
+		t.Fatal("expected error for unknown fault")
+	}
+}
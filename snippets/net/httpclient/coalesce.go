@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultCoalesceHeaders are the request headers that distinguish otherwise
+// identical requests when Options.CoalesceHeaders is nil.
+var defaultCoalesceHeaders = []string{"Authorization", "Cookie", "Accept", "Accept-Encoding", "Accept-Language"}
+
+// coalescer merges concurrent identical requests into a single call.
+type coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*sharedCall
+}
+
+type sharedCall struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	resp    *http.Response // body already consumed into body
+	body    []byte
+	timings []AttemptTiming
+	err     error
+}
+
+func coalescible(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+}
+
+func (co *coalescer) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.String())
+	for _, h := range co.headers {
+		b.WriteString("\x00")
+		b.WriteString(h)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// do runs fn once per key for all concurrent callers. The shared call runs
+// detached from any single caller's context and is only canceled when every
+// waiter has given up. Each caller receives its own copy of the response with
+// an independent body.
+func (co *coalescer) do(req *http.Request, fn func(*http.Request) (*http.Response, []AttemptTiming, error)) (*http.Response, []AttemptTiming, error) {
+	key := co.key(req)
+
+	co.mu.Lock()
+	if co.calls == nil {
+		co.calls = make(map[string]*sharedCall)
+	}
+	call, ok := co.calls[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+		call = &sharedCall{done: make(chan struct{}), cancel: cancel}
+		co.calls[key] = call
+		go co.run(key, call, req.WithContext(ctx), fn)
+	}
+	call.waiters++
+	co.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.timings, call.err
+		}
+		resp := *call.resp
+		resp.Header = call.resp.Header.Clone()
+		resp.Trailer = call.resp.Trailer.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(call.body))
+		resp.Request = req
+		return &resp, append([]AttemptTiming(nil), call.timings...), nil
+	case <-req.Context().Done():
+		co.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if co.calls[key] == call {
+				delete(co.calls, key)
+			}
+		}
+		co.mu.Unlock()
+		return nil, nil, req.Context().Err()
+	}
+}
+
+func (co *coalescer) run(key string, call *sharedCall, req *http.Request, fn func(*http.Request) (*http.Response, []AttemptTiming, error)) {
+	defer call.cancel()
+	resp, timings, err := fn(req)
+	if err == nil {
+		call.body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	call.resp, call.timings, call.err = resp, timings, err
+
+	co.mu.Lock()
+	if co.calls[key] == call {
+		delete(co.calls, key)
+	}
+	co.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesce_IdenticalGets(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte("catalog for " + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := New(Options{Coalesce: true})
+	get := func(ctx context.Context, auth string) (string, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		req.Header.Set("Authorization", auth)
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			auth := "a"
+			if i == 0 {
+				auth = "b" // different key: not merged
+			}
+			var err error
+			if results[i], err = get(context.Background(), auth); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	// A waiter that gives up must not affect the others.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := get(ctx, "a"); err != context.DeadlineExceeded {
+		t.Errorf("canceled waiter: got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", n)
+	}
+	for i, r := range results {
+		want := "catalog for a"
+		if i == 0 {
+			want = "catalog for b"
+		}
+		if r != want {
+			t.Fatalf("result %d = %q, want %q", i, r, want)
+		}
+	}
+}
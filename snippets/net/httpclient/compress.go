@@ -0,0 +1,156 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrDecompressedTooLarge is returned while reading a response body whose
+// decoded size exceeds CompressionOptions.MaxDecodedBytes.
+var ErrDecompressedTooLarge = errors.New("httpclient: decompressed body too large")
+
+// CompressionOptions configures request compression and response decoding.
+type CompressionOptions struct {
+	// RequestThreshold gzip-compresses request bodies whose ContentLength is at
+	// least this many bytes. Compressed bodies are streamed (chunked). Zero
+	// disables request compression.
+	RequestThreshold int64
+	// MaxDecodedBytes limits the decoded size of gzip/deflate responses, to
+	// guard against zip bombs. Defaults to 64 MiB.
+	MaxDecodedBytes int64
+}
+
+// compressTransport gzips large request bodies and decodes gzip/deflate
+// responses itself (instead of net/http) so that decoding also happens when
+// the caller set Accept-Encoding and is always size-limited.
+type compressTransport struct {
+	base http.RoundTripper
+	opt  CompressionOptions
+}
+
+func newCompressTransport(base http.RoundTripper, opt CompressionOptions) *compressTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opt.MaxDecodedBytes <= 0 {
+		opt.MaxDecodedBytes = 64 << 20
+	}
+	return &compressTransport{base: base, opt: opt}
+}
+
+func (t *compressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if out.Header.Get("Accept-Encoding") == "" {
+		out.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	if t.opt.RequestThreshold > 0 && req.ContentLength >= t.opt.RequestThreshold &&
+		req.Body != nil && req.Body != http.NoBody && req.Header.Get("Content-Encoding") == "" {
+		body := req.Body
+		out.Body = gzipPipe(body)
+		out.ContentLength = -1
+		out.Header.Del("Content-Length")
+		out.Header.Set("Content-Encoding", "gzip")
+		if req.GetBody != nil {
+			out.GetBody = func() (io.ReadCloser, error) {
+				b, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				return gzipPipe(b), nil
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if (enc != "gzip" && enc != "deflate") || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, nil
+	}
+	resp.Body = &decodingBody{src: resp.Body, enc: enc, left: t.opt.MaxDecodedBytes}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+func gzipPipe(src io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer src.Close()
+		zw := gzip.NewWriter(pw)
+		if _, err := io.Copy(zw, src); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(zw.Close())
+	}()
+	return pr
+}
+
+// decodingBody lazily decodes src on first Read, so RoundTrip does not block
+// on the compressed header.
+type decodingBody struct {
+	src  io.ReadCloser
+	enc  string
+	left int64
+	r    io.Reader
+	err  error
+}
+
+func (b *decodingBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = newDecoder(b.src, b.enc)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.left <= 0 {
+		// Only fail if there is actually more data.
+		var one [1]byte
+		if n, _ := b.r.Read(one[:]); n > 0 {
+			b.err = ErrDecompressedTooLarge
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func (b *decodingBody) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		c.Close()
+	}
+	return b.src.Close()
+}
+
+func newDecoder(src io.Reader, enc string) (io.Reader, error) {
+	if enc == "gzip" {
+		return gzip.NewReader(src)
+	}
+	// "deflate" should be zlib-wrapped (RFC 9110), but some servers send raw
+	// DEFLATE; sniff the zlib header to tell them apart.
+	br := bufio.NewReader(src)
+	hdr, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompression_RequestAndResponse(t *testing.T) {
+	payload := strings.Repeat(`{"event":"click"}`+"\n", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("request not compressed")
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		got, _ := io.ReadAll(zr)
+		if string(got) != payload {
+			t.Errorf("server got %d bytes", len(got))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("accepted " + r.Header.Get("Accept-Encoding")))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	c := New(Options{Compression: &CompressionOptions{RequestThreshold: 1024}})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, strings.NewReader(payload))
+	req.Header.Set("Accept-Encoding", "gzip") // caller-set: net/http would not decode
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || string(b) != "accepted gzip" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	if resp.Header.Get("Content-Encoding") != "" || !resp.Uncompressed {
+		t.Fatalf("response still marked as encoded")
+	}
+}
+
+func TestCompression_DecodedSizeLimit(t *testing.T) {
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	zw.Write(make([]byte, 10<<20))
+	zw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(bomb.Bytes())
+	}))
+	defer srv.Close()
+
+	c := New(Options{Compression: &CompressionOptions{MaxDecodedBytes: 1 << 20}})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if !errors.Is(err, ErrDecompressedTooLarge) || n != 1<<20 {
+		t.Fatalf("read %d bytes, err %v", n, err)
+	}
+}
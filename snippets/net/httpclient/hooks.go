@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Hooks observe the lifecycle of Client.Do. All fields are optional. Hooks
+// run synchronously on the calling goroutine and must not consume response
+// bodies.
+type Hooks struct {
+	// OnRequest is called before every attempt (attempt 0 is the first).
+	OnRequest func(req *http.Request, attempt int)
+	// OnResponse is called for every attempt that received a response,
+	// including responses that will be retried.
+	OnResponse func(req *http.Request, resp *http.Response, t AttemptTiming)
+	// OnRetry is called before backing off; attempt is the upcoming attempt
+	// and reason the error or status that caused the retry.
+	OnRetry func(req *http.Request, attempt int, wait time.Duration, reason error)
+	// OnGiveUp is called when Do returns an error after attempts attempts.
+	OnGiveUp func(req *http.Request, attempts int, err error)
+}
+
+// SlogOptions configures NewSlogHooks.
+type SlogOptions struct {
+	// RedactHeaders lists headers logged as Redacted in addition to
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie.
+	RedactHeaders []string
+	// RedactQuery lists query parameters (compared case-insensitively) whose
+	// values are logged as Redacted. nil means DefaultRedactQuery; pass an
+	// empty slice to log query strings verbatim.
+	RedactQuery []string
+}
+
+// DefaultRedactQuery lists query parameters that commonly carry credentials.
+var DefaultRedactQuery = []string{
+	"access_token", "refresh_token", "id_token", "token", "code",
+	"api_key", "apikey", "key", "client_secret", "password",
+	"sig", "signature", "X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token",
+}
+
+// NewSlogHooks returns Hooks that log through logger: requests and responses
+// at Debug, retries at Warn and give-ups at Error.
+//
+// Sensitive header and query parameter values are logged as Redacted (see
+// SlogOptions), and URLs are logged without user info passwords, including
+// inside error messages.
+func NewSlogHooks(logger *slog.Logger, opt SlogOptions) Hooks {
+	redacted := map[string]bool{}
+	for _, names := range [][]string{alwaysRedacted, opt.RedactHeaders} {
+		for _, n := range names {
+			redacted[http.CanonicalHeaderKey(n)] = true
+		}
+	}
+	query := opt.RedactQuery
+	if query == nil {
+		query = DefaultRedactQuery
+	}
+	redactedQuery := map[string]bool{}
+	for _, n := range query {
+		redactedQuery[strings.ToLower(n)] = true
+	}
+	headers := func(h http.Header) slog.Attr {
+		attrs := make([]any, 0, len(h))
+		for k, vs := range h {
+			v := strings.Join(vs, ", ")
+			if redacted[k] {
+				v = Redacted
+			}
+			attrs = append(attrs, slog.String(k, v))
+		}
+		return slog.Group("header", attrs...)
+	}
+	logURL := func(u *url.URL) slog.Attr {
+		return slog.String("url", redactURL(u, redactedQuery))
+	}
+	logErr := func(key string, err error) slog.Attr {
+		s := err.Error()
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			if u, perr := url.Parse(ue.URL); perr == nil {
+				s = strings.ReplaceAll(s, ue.URL, redactURL(u, redactedQuery))
+			}
+		}
+		return slog.String(key, s)
+	}
+
+	return Hooks{
+		OnRequest: func(req *http.Request, attempt int) {
+			logger.LogAttrs(req.Context(), slog.LevelDebug, "http request",
+				slog.String("method", req.Method),
+				logURL(req.URL),
+				slog.Int("attempt", attempt),
+				headers(req.Header))
+		},
+		OnResponse: func(req *http.Request, resp *http.Response, t AttemptTiming) {
+			logger.LogAttrs(req.Context(), slog.LevelDebug, "http response",
+				slog.String("method", req.Method),
+				logURL(req.URL),
+				slog.Int("attempt", t.Attempt),
+				slog.Int("status", resp.StatusCode),
+				slog.Duration("duration", t.Total),
+				slog.Duration("ttfb", t.TTFB),
+				headers(resp.Header))
+		},
+		OnRetry: func(req *http.Request, attempt int, wait time.Duration, reason error) {
+			logger.LogAttrs(req.Context(), slog.LevelWarn, "http retry",
+				slog.String("method", req.Method),
+				logURL(req.URL),
+				slog.Int("attempt", attempt),
+				slog.Duration("wait", wait),
+				logErr("reason", reason))
+		},
+		OnGiveUp: func(req *http.Request, attempts int, err error) {
+			logger.LogAttrs(req.Context(), slog.LevelError, "http give up",
+				slog.String("method", req.Method),
+				logURL(req.URL),
+				slog.Int("attempts", attempts),
+				logErr("error", err))
+		},
+	}
+}
+
+// redactURL returns u without its user info password and with the values of
+// the query parameters in names (lower-cased) replaced by Redacted. The order
+// and encoding of the other parameters are kept.
+func redactURL(u *url.URL, names map[string]bool) string {
+	if u.RawQuery == "" || len(names) == 0 {
+		return u.Redacted()
+	}
+	parts := strings.Split(u.RawQuery, "&")
+	for i, part := range parts {
+		name, _, _ := strings.Cut(part, "=")
+		if n, err := url.QueryUnescape(name); err == nil && names[strings.ToLower(n)] {
+			parts[i] = name + "=" + Redacted
+		}
+	}
+	c := *u
+	c.RawQuery = strings.Join(parts, "&")
+	return c.Redacted()
+}
@@ -0,0 +1,176 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHooksLifecycle(t *testing.T) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) < 2 {
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	var events []string
+	c := New(Options{Timeout: 2 * time.Second, MaxRetries: 1, BaseBackoff: time.Millisecond, Hooks: Hooks{
+		OnRequest:  func(_ *http.Request, attempt int) { events = append(events, "request") },
+		OnResponse: func(_ *http.Request, resp *http.Response, _ AttemptTiming) { events = append(events, "response") },
+		OnRetry:    func(_ *http.Request, attempt int, _ time.Duration, _ error) { events = append(events, "retry") },
+		OnGiveUp:   func(*http.Request, int, error) { events = append(events, "giveup") },
+	}})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := strings.Join(events, ","), "request,response,retry,request,response"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+
+	events = nil
+	atomic.StoreInt32(&n, -10)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := events[len(events)-1]; got != "giveup" {
+		t.Fatalf("last event = %s", got)
+	}
+}
+
+func TestSlogHooksRedact(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := New(Options{Timeout: 2 * time.Second, Hooks: NewSlogHooks(logger, SlogOptions{RedactHeaders: []string{"X-Api-Key"}})})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/items?page=2&Access_Token=secret-query&sig=secret%2Fsig", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-Api-Key", "secret-key")
+	req.Header.Set("X-Request-Id", "abc")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	out := buf.String()
+	if strings.Contains(out, "secret") {
+		t.Fatalf("secret leaked into log:\n%s", out)
+	}
+	for _, want := range []string{"http request", "http response", "header.X-Request-Id=abc", "header.Authorization=" + Redacted,
+		"page=2&Access_Token=" + Redacted + "&sig=" + Redacted} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log missing %q:\n%s", want, out)
+		}
+	}
+
+	// Custom parameters, also inside transport errors.
+	buf.Reset()
+	c = New(Options{MaxRetries: 1, BaseBackoff: time.Millisecond, Hooks: NewSlogHooks(logger, SlogOptions{RedactQuery: []string{"session"}})})
+	srv.Close()
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/?session=secret-session&page=1", nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected error")
+	}
+	out = buf.String()
+	if strings.Contains(out, "secret") || !strings.Contains(out, "http give up") || !strings.Contains(out, "session="+Redacted+"&page=1") {
+		t.Fatalf("custom query redaction:\n%s", out)
+	}
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []SpanRecord
+}
+
+func (r *recordingTracer) RecordSpan(_ context.Context, s SpanRecord) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+func TestTraceparentPropagation(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("traceparent"))
+		if len(got) == 1 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer srv.Close()
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &recordingTracer{}
+	c := New(Options{Timeout: 2 * time.Second, MaxRetries: 1, BaseBackoff: time.Millisecond, Tracer: tr})
+	req, _ := http.NewRequestWithContext(ContextWithSpanContext(context.Background(), parent), http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get("traceparent") != "" {
+		t.Fatal("caller's request header was modified")
+	}
+
+	if len(got) != 2 || got[0] == got[1] {
+		t.Fatalf("traceparent headers = %q", got)
+	}
+	for _, h := range got {
+		sc, err := ParseTraceparent(h)
+		if err != nil {
+			t.Fatalf("server saw %q: %v", h, err)
+		}
+		if sc.TraceID != parent.TraceID {
+			t.Fatalf("trace id not propagated: %s", h)
+		}
+	}
+
+	// Two attempt spans, then the call span, all in the parent's trace.
+	if len(tr.spans) != 3 {
+		t.Fatalf("got %d spans", len(tr.spans))
+	}
+	call := tr.spans[2]
+	if call.Name != "HTTP GET" || call.Parent != parent {
+		t.Fatalf("call span = %+v", call)
+	}
+	for _, s := range tr.spans[:2] {
+		if s.Parent != call.Span {
+			t.Fatalf("attempt span parent = %v, want call span", s.Parent)
+		}
+	}
+	if sc, _ := ParseTraceparent(got[1]); sc != tr.spans[1].Span {
+		t.Fatalf("header %s does not match attempt span", got[1])
+	}
+}
+
+func TestParseTraceparentRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("ParseTraceparent(%q) succeeded", s)
+		}
+	}
+}
@@ -3,30 +3,78 @@ package httpclient
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"syscall"
 	"time"
 )
 
 type Options struct {
+	// AttemptTimeout bounds a single attempt, including reading the response
+	// body. Defaults to 10s.
+	AttemptTimeout time.Duration
+	// TotalTimeout bounds the whole call: all attempts, the backoff between
+	// them and reading the final response body. Zero means only the request
+	// context limits it.
+	TotalTimeout time.Duration
+	// Timeout is the former name of AttemptTimeout and is used when
+	// AttemptTimeout is zero.
 	Timeout     time.Duration
 	MaxRetries  int
 	BaseBackoff time.Duration
 	// RetryStatuses: if empty, defaults to 429 and 5xx.
 	RetryStatuses map[int]bool
+	// Transport is the underlying RoundTripper; nil means http.DefaultTransport.
+	// Layers such as CacheTransport are plugged in here.
+	Transport http.RoundTripper
+	// TransportConfig, if set and Transport is nil, builds the transport with
+	// NewTransport. If the config is invalid, Do returns the error.
+	TransportConfig *TransportConfig
+	// OnAttempt, if set, is called after every attempt with its timings.
+	OnAttempt func(AttemptTiming)
+	// Hooks observe each call's lifecycle (see NewSlogHooks).
+	Hooks Hooks
+	// Tracer, if set, receives a span for each call and each attempt, and
+	// requests carry a W3C traceparent header. See SpanContext.
+	Tracer Tracer
+	// Compression enables gzip request bodies and size-limited decoding of
+	// gzip/deflate responses. See CompressionOptions.
+	Compression *CompressionOptions
+	// Coalesce merges concurrent identical GET and HEAD requests (same URL and
+	// CoalesceHeaders) into one upstream call, including its retries. Every
+	// caller gets an independent copy of the buffered response body.
+	Coalesce bool
+	// CoalesceHeaders are the request headers that make requests distinct.
+	// Defaults to Authorization, Cookie, Accept, Accept-Encoding and
+	// Accept-Language.
+	CoalesceHeaders []string
 }
 
+// ErrBudgetExhausted is returned (wrapping the last attempt's error) when the
+// remaining TotalTimeout or context deadline cannot fit another attempt.
+var ErrBudgetExhausted = errors.New("httpclient: retry budget exhausted")
+
 type Client struct {
-	hc   *http.Client
-	opt  Options
+	hc  *http.Client
+	opt Options
+	err error // invalid TransportConfig
+	co  *coalescer
+
+	mu   sync.Mutex // guards rand
 	rand *rand.Rand
 }
 
 func New(opt Options) *Client {
-	if opt.Timeout == 0 {
-		opt.Timeout = 10 * time.Second
+	if opt.AttemptTimeout == 0 {
+		opt.AttemptTimeout = opt.Timeout
+	}
+	if opt.AttemptTimeout == 0 {
+		opt.AttemptTimeout = 10 * time.Second
 	}
 	if opt.BaseBackoff == 0 {
 		opt.BaseBackoff = 200 * time.Millisecond
@@ -37,19 +85,110 @@ func New(opt Options) *Client {
 			opt.RetryStatuses[s] = true
 		}
 	}
-	return &Client{
-		hc: &http.Client{Timeout: opt.Timeout},
-		opt: opt,
+	c := &Client{
+		hc:   &http.Client{Timeout: opt.AttemptTimeout, Transport: opt.Transport},
+		opt:  opt,
 		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	if opt.Coalesce {
+		headers := opt.CoalesceHeaders
+		if headers == nil {
+			headers = defaultCoalesceHeaders
+		}
+		c.co = &coalescer{headers: headers}
+	}
+	if opt.Transport == nil && opt.TransportConfig != nil {
+		t, err := NewTransport(*opt.TransportConfig)
+		if err != nil {
+			c.err = err
+		} else {
+			c.hc.Transport = t
+		}
+	}
+	if opt.Compression != nil {
+		c.hc.Transport = newCompressTransport(c.hc.Transport, *opt.Compression)
+	}
+	return c
 }
 
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	var lastErr error
+	resp, _, err := c.DoTimed(req)
+	return resp, err
+}
+
+// DoTimed is like Do and also returns the timings of every attempt made.
+//
+// Before backing off it checks the deadline (TotalTimeout or the request
+// context's): if the backoff plus the fastest attempt seen so far would not
+// fit, it stops and returns an error wrapping ErrBudgetExhausted.
+func (c *Client) DoTimed(req *http.Request) (*http.Response, []AttemptTiming, error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	if c.co != nil && coalescible(req) {
+		return c.co.do(req, c.doTimed)
+	}
+	return c.doTimed(req)
+}
+
+func (c *Client) doTimed(req *http.Request) (*http.Response, []AttemptTiming, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if c.opt.TotalTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.opt.TotalTimeout)
+	}
+	span := c.startCallSpan(ctx, req)
+
+	resp, timings, err := c.attempts(ctx, req, span)
+	if err != nil {
+		cancel()
+		if h := c.opt.Hooks.OnGiveUp; h != nil {
+			h(req, len(timings), err)
+		}
+	} else {
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+	span.end(ctx, resp, len(timings), err)
+	return resp, timings, err
+}
+
+func (c *Client) attempts(ctx context.Context, req *http.Request, span *callSpan) (*http.Response, []AttemptTiming, error) {
+	var (
+		timings []AttemptTiming
+		lastErr error
+		fastest time.Duration
+	)
 	for attempt := 0; attempt <= c.opt.MaxRetries; attempt++ {
-		resp, err := c.hc.Do(req)
+		r := req
+		if attempt > 0 {
+			// The previous attempt consumed the body; rewind it if possible.
+			var err error
+			r, err = rewindBody(req)
+			if err != nil {
+				return nil, timings, fmt.Errorf("%w (after: %v)", err, lastErr)
+			}
+		}
+		tc := newTimingCollector(attempt)
+		r = r.WithContext(httptrace.WithClientTrace(ctx, tc.trace()))
+		attemptSC := span.inject(r)
+		if h := c.opt.Hooks.OnRequest; h != nil {
+			h(r, attempt)
+		}
+		resp, err := c.hc.Do(r)
+		timing := tc.finish(resp, err)
+		timings = append(timings, timing)
+		span.recordAttempt(ctx, r, attemptSC, timing)
+		if c.opt.OnAttempt != nil {
+			c.opt.OnAttempt(timing)
+		}
+		if h := c.opt.Hooks.OnResponse; h != nil && resp != nil {
+			h(r, resp, timing)
+		}
+		if fastest == 0 || timing.Total < fastest {
+			fastest = timing.Total
+		}
+
 		if err == nil && resp != nil && !c.shouldRetryStatus(resp.StatusCode) {
-			return resp, nil
+			return resp, timings, nil
 		}
 
 		// If we got a response that we plan to retry, drain body to reuse TCP conn.
@@ -60,42 +199,99 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 
 		if err != nil {
 			lastErr = err
-			if !isTransientNetErr(err) {
+			if !isTransientNetErr(err) || ctx.Err() != nil {
 				break
 			}
 		} else {
-			lastErr = errors.New("retryable http status")
+			lastErr = fmt.Errorf("httpclient: retryable status %d", resp.StatusCode)
 		}
 
 		if attempt == c.opt.MaxRetries {
 			break
 		}
 
-		if err := sleepWithBackoff(req.Context(), c.opt.BaseBackoff, attempt, c.rand); err != nil {
-			return nil, err
+		wait := c.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait+fastest {
+			lastErr = fmt.Errorf("%w: %w", ErrBudgetExhausted, lastErr)
+			break
+		}
+		if h := c.opt.Hooks.OnRetry; h != nil {
+			h(req, attempt+1, wait, lastErr)
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, timings, err
 		}
 	}
-	return nil, lastErr
+	return nil, timings, lastErr
+}
+
+// rewindBody returns a shallow copy of req with a fresh body obtained from
+// req.GetBody. Requests without a body are returned unchanged.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("httpclient: cannot retry request with non-rewindable body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
 }
 
 func (c *Client) shouldRetryStatus(code int) bool {
 	return c.opt.RetryStatuses[code]
 }
 
-func sleepWithBackoff(ctx context.Context, base time.Duration, attempt int, r *rand.Rand) error {
-	// expo backoff: base * 2^attempt, add jitter [0, base)
-	sleep := base * time.Duration(1<<attempt)
-	jitter := time.Duration(r.Int63n(int64(base)))
-	wait := sleep + jitter
+// backoff returns base * 2^attempt plus jitter in [0, base).
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.opt.BaseBackoff
+	c.mu.Lock()
+	jitter := time.Duration(c.rand.Int63n(int64(base)))
+	c.mu.Unlock()
+	return base*time.Duration(1<<attempt) + jitter
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 	select {
-	case <-time.After(wait):
+	case <-timer.C:
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
+// cancelOnClose releases the call's context (see TotalTimeout) once the
+// caller is done with the body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 func isTransientNetErr(err error) bool {
+	// A replay miss is deterministic; retrying only delays the failure.
+	var unmatched *UnmatchedRequestError
+	if errors.As(err, &unmatched) {
+		return false
+	}
+	// Resets and unexpected EOFs typically mean the server or a proxy closed a
+	// (kept-alive) connection; net.Error does not report them as temporary.
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
 	var ne net.Error
 	if errors.As(err, &ne) {
 		return ne.Timeout() || ne.Temporary()
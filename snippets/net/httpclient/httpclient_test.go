@@ -2,6 +2,7 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -34,3 +35,41 @@ func TestRetryOn5xx(t *testing.T) {
 		t.Fatalf("expected retries, got %d", n)
 	}
 }
+
+func TestTotalTimeoutStopsRetries(t *testing.T) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+
+	var seen []AttemptTiming
+	c := New(Options{
+		AttemptTimeout: time.Second,
+		TotalTimeout:   200 * time.Millisecond,
+		MaxRetries:     10,
+		BaseBackoff:    40 * time.Millisecond,
+		OnAttempt:      func(at AttemptTiming) { seen = append(seen, at) },
+	})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	_, timings, err := c.DoTimed(req)
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("expected ErrBudgetExhausted, got %v", err)
+	}
+	if el := time.Since(start); el > 200*time.Millisecond {
+		t.Fatalf("exceeded total timeout: %v", el)
+	}
+	if len(timings) != int(atomic.LoadInt32(&n)) || len(seen) != len(timings) {
+		t.Fatalf("timings %d, hook %d, server hits %d", len(timings), len(seen), n)
+	}
+	first := timings[0]
+	if first.StatusCode != 503 || first.Connect == 0 || first.TTFB < 30*time.Millisecond {
+		t.Fatalf("unexpected first attempt timing %+v", first)
+	}
+	if len(timings) > 1 && !timings[1].ReusedConn {
+		t.Fatalf("expected the retry to reuse the connection: %+v", timings[1])
+	}
+}
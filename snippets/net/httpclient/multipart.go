@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Multipart builds a streaming multipart/form-data request body.
+//
+// Files are read while the request is being sent (through an io.Pipe), so
+// nothing is buffered in memory. Nothing is opened until the transport first
+// reads the body. The request's GetBody regenerates the body, which lets
+// Client.Do retry uploads.
+type Multipart struct {
+	// OnProgress, if set, is called as body bytes are sent. total is -1 when
+	// the size of some part is unknown. It is called again from zero on retry.
+	// Calls for one request never overlap: GetBody stops the previous
+	// attempt's writer before starting a new one.
+	OnProgress func(written, total int64)
+
+	boundary string
+	parts    []formPart
+}
+
+type formPart struct {
+	field, filename, contentType string
+	size                         int64 // -1 if unknown
+	open                         func() (io.ReadCloser, error)
+}
+
+// NewMultipart returns an empty builder with a random boundary.
+func NewMultipart() *Multipart {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return &Multipart{boundary: hex.EncodeToString(b[:])}
+}
+
+// AddField adds a plain form field.
+func (m *Multipart) AddField(name, value string) {
+	m.parts = append(m.parts, formPart{
+		field: name,
+		size:  int64(len(value)),
+		open:  func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(value)), nil },
+	})
+}
+
+// AddFile adds the file at path. It is opened each time the body is
+// (re)generated.
+func (m *Multipart) AddFile(field, path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("httpclient: multipart file: %w", err)
+	}
+	m.parts = append(m.parts, formPart{
+		field:       field,
+		filename:    filepath.Base(path),
+		contentType: "application/octet-stream",
+		size:        st.Size(),
+		open:        func() (io.ReadCloser, error) { return os.Open(path) },
+	})
+	return nil
+}
+
+// AddReader adds a file part whose content comes from open. size may be -1 if
+// unknown, in which case the request is sent chunked. open is called once per
+// attempt, so it must return the full content every time.
+func (m *Multipart) AddReader(field, filename, contentType string, size int64, open func() (io.ReadCloser, error)) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, formPart{field: field, filename: filename, contentType: contentType, size: size, open: open})
+}
+
+// FormDataContentType returns the Content-Type header value.
+func (m *Multipart) FormDataContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// ContentLength returns the exact body size, or -1 if a part's size is unknown.
+func (m *Multipart) ContentLength() int64 {
+	var cw countWriter
+	w := multipart.NewWriter(&cw)
+	_ = w.SetBoundary(m.boundary)
+	for _, p := range m.parts {
+		if p.size < 0 {
+			return -1
+		}
+		if _, err := w.CreatePart(p.header()); err != nil {
+			return -1
+		}
+		cw.n += p.size
+	}
+	_ = w.Close()
+	return cw.n
+}
+
+// NewRequest returns a request streaming the multipart body.
+func (m *Multipart) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	total := m.ContentLength()
+	req.Header.Set("Content-Type", m.FormDataContentType())
+	req.ContentLength = total
+	var mu sync.Mutex
+	var cur *multipartBody
+	req.GetBody = func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cur != nil {
+			cur.Close()
+			<-cur.done // the previous writer has stopped (and closed its files)
+		}
+		cur = &multipartBody{m: m, total: total, done: make(chan struct{})}
+		return cur, nil
+	}
+	req.Body, _ = req.GetBody()
+	return req, nil
+}
+
+// multipartBody is one attempt's body. Its writer goroutine starts on the
+// first Read, so a request that is never sent holds no goroutine or files.
+type multipartBody struct {
+	m     *Multipart
+	total int64
+	once  sync.Once
+	pr    *io.PipeReader // nil if closed before the first Read
+	done  chan struct{}  // closed once no writer is running
+}
+
+func (b *multipartBody) start() {
+	pr, pw := io.Pipe()
+	b.pr = pr
+	go func() {
+		defer close(b.done)
+		var out io.Writer = pw
+		if b.m.OnProgress != nil {
+			out = &progressWriter{w: pw, total: b.total, fn: b.m.OnProgress}
+		}
+		pw.CloseWithError(b.m.write(out))
+	}()
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(b.start)
+	if b.pr == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return b.pr.Read(p)
+}
+
+// Close stops the writer, which then fails its next write and returns.
+func (b *multipartBody) Close() error {
+	b.once.Do(func() { close(b.done) })
+	if b.pr != nil {
+		return b.pr.Close()
+	}
+	return nil
+}
+
+func (m *Multipart) write(out io.Writer) error {
+	w := multipart.NewWriter(out)
+	if err := w.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		pw, err := w.CreatePart(p.header())
+		if err != nil {
+			return err
+		}
+		rc, err := p.open()
+		if err != nil {
+			return fmt.Errorf("httpclient: multipart open %s: %w", p.field, err)
+		}
+		n, err := io.Copy(pw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if p.size >= 0 && n != p.size {
+			return fmt.Errorf("httpclient: multipart %s: size changed (%d != %d)", p.field, n, p.size)
+		}
+	}
+	return w.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p formPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	disp := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.field))
+	if p.filename != "" {
+		disp += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.filename))
+	}
+	h.Set("Content-Disposition", disp)
+	if p.contentType != "" {
+		h.Set("Content-Type", p.contentType)
+	}
+	return h
+}
+
+type countWriter struct{ n int64 }
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	fn      func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.fn(p.written, p.total)
+	return n, err
+}
@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultipart_StreamAndRetry(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100_000) // 1 MB
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse: %v", err)
+			return
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+			return
+		}
+		got, _ := io.ReadAll(f)
+		if hdr.Filename != "report.csv" || !bytes.Equal(got, data) || r.FormValue("kind") != "daily" {
+			t.Errorf("unexpected upload: %s, %d bytes, kind %q", hdr.Filename, len(got), r.FormValue("kind"))
+		}
+	}))
+	defer srv.Close()
+
+	m := NewMultipart()
+	m.AddField("kind", "daily")
+	if err := m.AddFile("file", path); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var lastWritten, lastTotal int64
+	var inProgress atomic.Int32
+	m.OnProgress = func(written, total int64) {
+		if inProgress.Add(1) != 1 {
+			t.Error("concurrent OnProgress calls")
+		}
+		mu.Lock()
+		lastWritten, lastTotal = written, total
+		mu.Unlock()
+		inProgress.Add(-1)
+	}
+
+	req, err := m.NewRequest(context.Background(), http.MethodPost, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength <= int64(len(data)) {
+		t.Fatalf("content length %d", req.ContentLength)
+	}
+	c := New(Options{MaxRetries: 2, BaseBackoff: time.Millisecond})
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("status %d after %d attempts", resp.StatusCode, attempts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if lastWritten != req.ContentLength || lastTotal != req.ContentLength {
+		t.Fatalf("progress %d/%d, content length %d", lastWritten, lastTotal, req.ContentLength)
+	}
+}
+
+func TestMultipart_LazyBody(t *testing.T) {
+	var opens, closes atomic.Int32
+	m := NewMultipart()
+	m.AddReader("file", "a.txt", "", 5, func() (io.ReadCloser, error) {
+		opens.Add(1)
+		return &trackingCloser{Reader: strings.NewReader("hello"), closes: &closes}, nil
+	})
+
+	// Building (and abandoning) a request opens nothing.
+	req, err := m.NewRequest(context.Background(), http.MethodPost, "http://example.invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opens.Load() != 0 {
+		t.Fatalf("opened %d parts before sending", opens.Load())
+	}
+
+	// A new attempt stops the previous one, which closes its part.
+	first, _ := req.GetBody()
+	var sent []byte
+	for !strings.Contains(string(sent), "hel") {
+		buf := make([]byte, 1)
+		if _, err := first.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, buf...)
+	}
+	second, _ := req.GetBody()
+	if opens.Load() != 1 || closes.Load() != 1 {
+		t.Fatalf("after retry: %d opens, %d closes", opens.Load(), closes.Load())
+	}
+	if _, err := first.Read(make([]byte, 1)); err == nil {
+		t.Fatal("previous attempt still readable")
+	}
+	body, err := io.ReadAll(second)
+	if err != nil || !strings.Contains(string(body), "hello") {
+		t.Fatalf("second attempt: %q, %v", body, err)
+	}
+	second.Close()
+	req.Body.Close()
+}
+
+type trackingCloser struct {
+	io.Reader
+	closes *atomic.Int32
+}
+
+func (c *trackingCloser) Close() error {
+	c.closes.Add(1)
+	return nil
+}
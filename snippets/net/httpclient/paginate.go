@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Page is one decoded page of results.
+type Page[T any] struct {
+	Items []T
+	// Cursor is the next-page cursor for cursor-based APIs. An empty cursor
+	// ends the iteration. It is ignored when following Link headers.
+	Cursor string
+}
+
+// PageOptions configures Paginate.
+type PageOptions[T any] struct {
+	// Decode decodes one page. Defaults to DecodeJSONArray.
+	Decode func(resp *http.Response) (Page[T], error)
+	// NextRequest builds the next page's request from the previous request and
+	// the cursor returned by Decode (see QueryCursor). If nil, the RFC 8288
+	// Link rel="next" header is followed instead.
+	NextRequest func(prev *http.Request, cursor string) (*http.Request, error)
+	// MaxPages bounds the number of pages fetched; 0 means no limit.
+	MaxPages int
+}
+
+// Paginate iterates over the items of a paginated API, starting at req.
+//
+// Pages are fetched lazily: the next page is requested only once the loop has
+// consumed every item of the current one. Breaking out of the loop stops
+// fetching. Errors (including a canceled request context or a non-2xx status)
+// are yielded once as (zero, err) and end the iteration. Each range over the
+// returned sequence starts again from req. A Link header pointing at another
+// scheme, host or port is followed without req's credential headers
+// (Authorization, Cookie, ...).
+//
+//	for item, err := range httpclient.Paginate(c, req, httpclient.PageOptions[Repo]{}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Paginate[T any](c *Client, req *http.Request, opt PageOptions[T]) iter.Seq2[T, error] {
+	decode := opt.Decode
+	if decode == nil {
+		decode = DecodeJSONArray[T]
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		ctx := req.Context()
+		r := req // each range starts over at req
+		for pages := 0; r != nil && (opt.MaxPages <= 0 || pages < opt.MaxPages); pages++ {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			page, next, err := fetchPage(c, r, decode, opt.NextRequest)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if err := ctx.Err(); err != nil {
+					yield(zero, err)
+					return
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+			r = next
+		}
+	}
+}
+
+func fetchPage[T any](c *Client, req *http.Request, decode func(*http.Response) (Page[T], error),
+	nextReq func(*http.Request, string) (*http.Request, error)) (Page[T], *http.Request, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return Page[T]{}, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return Page[T]{}, nil, fmt.Errorf("httpclient: page %s: status %d", req.URL, resp.StatusCode)
+	}
+	page, err := decode(resp)
+	if err != nil {
+		return Page[T]{}, nil, fmt.Errorf("httpclient: decode page %s: %w", req.URL, err)
+	}
+
+	if nextReq != nil {
+		if page.Cursor == "" {
+			return page, nil, nil
+		}
+		next, err := nextReq(req, page.Cursor)
+		return page, next, err
+	}
+	u, ok := NextLink(resp)
+	if !ok {
+		return page, nil, nil
+	}
+	next := req.Clone(req.Context())
+	if !sameOrigin(req.URL, u) {
+		// The server chose this URL; like net/http on redirects, do not hand
+		// the caller's credentials to another origin.
+		for _, h := range credentialHeaders {
+			next.Header.Del(h)
+		}
+	}
+	next.URL = u
+	next.Host = ""
+	return page, next, nil
+}
+
+// credentialHeaders are dropped when a next link leaves the original origin.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Cookie2", "WWW-Authenticate"}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(canonicalHostPort(a), canonicalHostPort(b))
+}
+
+func canonicalHostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[strings.ToLower(u.Scheme)]
+	}
+	return u.Hostname() + ":" + port
+}
+
+// DecodeJSONArray decodes a response body holding a JSON array of T.
+func DecodeJSONArray[T any](resp *http.Response) (Page[T], error) {
+	var items []T
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return Page[T]{}, err
+	}
+	return Page[T]{Items: items}, nil
+}
+
+// QueryCursor returns a PageOptions.NextRequest that copies the previous
+// request and sets the query parameter param to the cursor.
+func QueryCursor(param string) func(prev *http.Request, cursor string) (*http.Request, error) {
+	return func(prev *http.Request, cursor string) (*http.Request, error) {
+		next := prev.Clone(prev.Context())
+		u := *prev.URL
+		q := u.Query()
+		q.Set(param, cursor)
+		u.RawQuery = q.Encode()
+		next.URL = &u
+		return next, nil
+	}
+}
+
+// Link is one link-value of an RFC 8288 Link header.
+type Link struct {
+	URL    string
+	Rel    string
+	Params map[string]string
+}
+
+// NextLink returns the rel="next" target of resp's Link headers, resolved
+// against the request URL.
+func NextLink(resp *http.Response) (*url.URL, bool) {
+	for _, h := range resp.Header.Values("Link") {
+		for _, l := range ParseLinkHeader(h) {
+			for _, rel := range strings.Fields(l.Rel) {
+				if !strings.EqualFold(rel, "next") {
+					continue
+				}
+				u, err := url.Parse(l.URL)
+				if err != nil {
+					return nil, false
+				}
+				if resp.Request != nil && resp.Request.URL != nil {
+					u = resp.Request.URL.ResolveReference(u)
+				}
+				return u, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ParseLinkHeader parses a Link header value such as
+//
+//	<https://api.example.com/items?page=2>; rel="next", <...?page=9>; rel="last"
+//
+// Malformed link-values are skipped.
+func ParseLinkHeader(h string) []Link {
+	var links []Link
+	for len(h) > 0 {
+		h = strings.TrimLeft(h, " \t,")
+		if !strings.HasPrefix(h, "<") {
+			// Skip to the next link-value.
+			i := strings.IndexByte(h, ',')
+			if i < 0 {
+				break
+			}
+			h = h[i+1:]
+			continue
+		}
+		end := strings.IndexByte(h, '>')
+		if end < 0 {
+			break
+		}
+		l := Link{URL: h[1:end], Params: map[string]string{}}
+		h = h[end+1:]
+
+		// Parameters: ; name=value or ; name="quoted, value"
+		for {
+			h = strings.TrimLeft(h, " \t")
+			if !strings.HasPrefix(h, ";") {
+				break
+			}
+			h = strings.TrimLeft(h[1:], " \t")
+			i := strings.IndexAny(h, "=;,")
+			if i < 0 {
+				i = len(h)
+			}
+			name := strings.ToLower(strings.TrimSpace(h[:i]))
+			h = h[i:]
+			var val string
+			if strings.HasPrefix(h, "=") {
+				h = strings.TrimLeft(h[1:], " \t")
+				if strings.HasPrefix(h, `"`) {
+					j := 1
+					var b strings.Builder
+					for ; j < len(h) && h[j] != '"'; j++ {
+						if h[j] == '\\' && j+1 < len(h) {
+							j++
+						}
+						b.WriteByte(h[j])
+					}
+					val = b.String()
+					h = h[min(j+1, len(h)):]
+				} else {
+					k := strings.IndexAny(h, ";,")
+					if k < 0 {
+						k = len(h)
+					}
+					val = strings.TrimSpace(h[:k])
+					h = h[k:]
+				}
+			}
+			if _, dup := l.Params[name]; !dup && name != "" {
+				l.Params[name] = val
+			}
+		}
+		l.Rel = l.Params["rel"]
+		links = append(links, l)
+	}
+	return links
+}
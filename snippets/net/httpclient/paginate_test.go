@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPaginate_LinkHeader(t *testing.T) {
+	var fetched int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=3>; rel="last"`, page+1))
+		}
+		json.NewEncoder(w).Encode([]int{page*10 + 1, page*10 + 2})
+	}))
+	defer srv.Close()
+
+	c := New(Options{})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/items", nil)
+	var got []int
+	for item, err := range Paginate(c, req, PageOptions[int]{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item)
+	}
+	if fmt.Sprint(got) != "[11 12 21 22 31 32]" {
+		t.Fatalf("got %v", got)
+	}
+
+	// Breaking early stops fetching further pages.
+	atomic.StoreInt32(&fetched, 0)
+	for item := range Paginate(c, req, PageOptions[int]{}) {
+		if item == 21 {
+			break
+		}
+	}
+	if n := atomic.LoadInt32(&fetched); n != 2 {
+		t.Fatalf("expected 2 pages fetched, got %d", n)
+	}
+}
+
+func TestPaginate_CrossOriginLink(t *testing.T) {
+	var leaked atomic.Value
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(r.Header.Get("Authorization") + r.Header.Get("Cookie"))
+		json.NewEncoder(w).Encode([]int{3})
+	}))
+	defer other.Close()
+	var sameOriginAuth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</?page=2>; rel="next"`)
+			json.NewEncoder(w).Encode([]int{1})
+			return
+		}
+		sameOriginAuth.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Link", "<"+other.URL+"/steal>; rel=\"next\"")
+		json.NewEncoder(w).Encode([]int{2})
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("Accept", "application/json")
+	var got []int
+	for item, err := range Paginate(New(Options{}), req, PageOptions[int]{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item)
+	}
+	if fmt.Sprint(got) != "[1 2 3]" {
+		t.Fatalf("got %v", got)
+	}
+	if v := sameOriginAuth.Load(); v != "Bearer secret" {
+		t.Fatalf("same-origin page lost Authorization: %q", v)
+	}
+	if v := leaked.Load(); v != "" {
+		t.Fatalf("credentials sent to another origin: %q", v)
+	}
+	if req.Header.Get("Authorization") == "" {
+		t.Fatal("caller's request was modified")
+	}
+}
+
+func TestPaginate_Reusable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`</?page=%d>; rel="next"`, page+1))
+		}
+		json.NewEncoder(w).Encode([]int{page})
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	seq := Paginate(New(Options{}), req, PageOptions[int]{})
+	collect := func() string {
+		var got []int
+		for item, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, item)
+		}
+		return fmt.Sprint(got)
+	}
+	for item := range seq {
+		if item == 1 {
+			break // a partial range must not leave the sequence mid-stream
+		}
+	}
+	first, second := collect(), collect()
+	if first != "[0 1 2]" || second != first {
+		t.Fatalf("first range %s, second %s", first, second)
+	}
+}
+
+func TestPaginate_Cursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next := map[string]string{"": "b", "b": "c", "c": ""}[r.URL.Query().Get("cursor")]
+		json.NewEncoder(w).Encode(map[string]any{"data": []string{"x" + next}, "next_cursor": next})
+	}))
+	defer srv.Close()
+
+	type resp struct {
+		Data       []string `json:"data"`
+		NextCursor string   `json:"next_cursor"`
+	}
+	opt := PageOptions[string]{
+		Decode: func(r *http.Response) (Page[string], error) {
+			var p resp
+			err := json.NewDecoder(r.Body).Decode(&p)
+			return Page[string]{Items: p.Data, Cursor: p.NextCursor}, err
+		},
+		NextRequest: QueryCursor("cursor"),
+	}
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	var got []string
+	for item, err := range Paginate(New(Options{}), req, opt) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item)
+	}
+	if fmt.Sprint(got) != "[xb xc x]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<https://x/?a=1,2>; rel="next prev"; title="a, b", <https://x/last>;rel=last`)
+	if len(links) != 2 || links[0].URL != "https://x/?a=1,2" || links[0].Rel != "next prev" ||
+		links[0].Params["title"] != "a, b" || links[1].Rel != "last" {
+		t.Fatalf("got %+v", links)
+	}
+}
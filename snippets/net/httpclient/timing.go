@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// AttemptTiming describes one attempt made by Client.DoTimed.
+//
+// Phase durations are zero when the phase did not happen, e.g. DNS, Connect
+// and TLS on a reused connection.
+type AttemptTiming struct {
+	Attempt int // 0 for the first attempt
+	Start   time.Time
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TTFB is the time from Start to the first response byte.
+	TTFB time.Duration
+	// Total is the time from Start until the response headers were received
+	// (or the attempt failed).
+	Total      time.Duration
+	ReusedConn bool
+	StatusCode int
+	Err        error
+}
+
+type timingCollector struct {
+	mu sync.Mutex
+	t  AttemptTiming
+
+	dnsStart, connStart, tlsStart time.Time
+}
+
+func newTimingCollector(attempt int) *timingCollector {
+	return &timingCollector{t: AttemptTiming{Attempt: attempt, Start: time.Now()}}
+}
+
+// trace returns hooks recording into tc. Hooks may fire concurrently (e.g.
+// parallel dials for IPv4 and IPv6), hence the mutex.
+func (tc *timingCollector) trace() *httptrace.ClientTrace {
+	record := func(f func()) {
+		tc.mu.Lock()
+		f()
+		tc.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(func() { tc.dnsStart = time.Now() }) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { tc.t.DNS = time.Since(tc.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() {
+				if tc.connStart.IsZero() {
+					tc.connStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			record(func() {
+				if err == nil && tc.t.Connect == 0 {
+					tc.t.Connect = time.Since(tc.connStart)
+				}
+			})
+		},
+		TLSHandshakeStart: func() { record(func() { tc.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { tc.t.TLS = time.Since(tc.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { tc.t.ReusedConn = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { tc.t.TTFB = time.Since(tc.t.Start) })
+		},
+	}
+}
+
+func (tc *timingCollector) finish(resp *http.Response, err error) AttemptTiming {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	t := tc.t
+	t.Total = time.Since(t.Start)
+	t.Err = err
+	if resp != nil {
+		t.StatusCode = resp.StatusCode
+	}
+	return t
+}
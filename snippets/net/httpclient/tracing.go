@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SpanContext identifies a span in a W3C Trace Context trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte // 0x01 = sampled
+}
+
+// IsValid reports whether both IDs are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses a version-00 traceparent header value.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.New("httpclient: malformed traceparent")
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errors.New("httpclient: malformed traceparent")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errors.New("httpclient: malformed traceparent")
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, errors.New("httpclient: malformed traceparent")
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.New("httpclient: traceparent with zero id")
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context carrying sc as the current span.
+// Requests made with it become children of sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span stored by ContextWithSpanContext.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// SpanRecord is a finished span reported to a Tracer.
+type SpanRecord struct {
+	Name   string
+	Span   SpanContext
+	Parent SpanContext // zero for root spans
+	Start  time.Time
+	End    time.Time
+	Attrs  []slog.Attr
+	Err    error
+}
+
+// Tracer receives finished spans. Adapt it to your tracing backend; it is
+// called synchronously, so hand spans off to a queue if exporting is slow.
+type Tracer interface {
+	RecordSpan(ctx context.Context, s SpanRecord)
+}
+
+// callSpan tracks the span of one Client.Do call. A nil *callSpan disables
+// tracing; all methods are nil-safe.
+type callSpan struct {
+	tracer Tracer
+	parent SpanContext
+	span   SpanContext
+	start  time.Time
+	method string
+	url    string
+}
+
+// startCallSpan starts a span if a Tracer is configured or the context (or
+// the request's own traceparent header) carries a parent span to propagate.
+func (c *Client) startCallSpan(ctx context.Context, req *http.Request) *callSpan {
+	parent, ok := SpanContextFromContext(ctx)
+	if !ok {
+		if p, err := ParseTraceparent(req.Header.Get("traceparent")); err == nil {
+			parent, ok = p, true
+		}
+	}
+	if c.opt.Tracer == nil && !ok {
+		return nil
+	}
+	cs := &callSpan{
+		tracer: c.opt.Tracer,
+		parent: parent,
+		start:  time.Now(),
+		method: req.Method,
+		url:    req.URL.Redacted(),
+	}
+	cs.span = childSpan(parent)
+	return cs
+}
+
+// inject sets a traceparent header for a new attempt span on r (copying the
+// header map, which may be shared with the caller's request).
+func (cs *callSpan) inject(r *http.Request) SpanContext {
+	if cs == nil {
+		return SpanContext{}
+	}
+	sc := childSpan(cs.span)
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set("traceparent", sc.Traceparent())
+	return sc
+}
+
+func (cs *callSpan) recordAttempt(ctx context.Context, r *http.Request, sc SpanContext, t AttemptTiming) {
+	if cs == nil || cs.tracer == nil {
+		return
+	}
+	cs.tracer.RecordSpan(ctx, SpanRecord{
+		Name:   "HTTP " + r.Method + " attempt",
+		Span:   sc,
+		Parent: cs.span,
+		Start:  t.Start,
+		End:    t.Start.Add(t.Total),
+		Attrs: []slog.Attr{
+			slog.Int("http.attempt", t.Attempt),
+			slog.Int("http.status_code", t.StatusCode),
+			slog.Duration("http.dns", t.DNS),
+			slog.Duration("http.connect", t.Connect),
+			slog.Duration("http.tls", t.TLS),
+			slog.Duration("http.ttfb", t.TTFB),
+			slog.Bool("http.reused_conn", t.ReusedConn),
+		},
+		Err: t.Err,
+	})
+}
+
+func (cs *callSpan) end(ctx context.Context, resp *http.Response, attempts int, err error) {
+	if cs == nil || cs.tracer == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("http.method", cs.method),
+		slog.String("http.url", cs.url),
+		slog.Int("http.attempts", attempts),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("http.status_code", resp.StatusCode))
+	}
+	cs.tracer.RecordSpan(ctx, SpanRecord{
+		Name:   "HTTP " + cs.method,
+		Span:   cs.span,
+		Parent: cs.parent,
+		Start:  cs.start,
+		End:    time.Now(),
+		Attrs:  attrs,
+		Err:    err,
+	})
+}
+
+// childSpan returns a new span in parent's trace, or a new sampled root span
+// if parent is zero.
+func childSpan(parent SpanContext) SpanContext {
+	sc := SpanContext{TraceID: parent.TraceID, Flags: parent.Flags}
+	if !parent.IsValid() {
+		rand.Read(sc.TraceID[:])
+		sc.Flags = 0x01
+	}
+	rand.Read(sc.SpanID[:])
+	return sc
+}
@@ -0,0 +1,280 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportConfig describes an *http.Transport: TLS (custom CAs, client
+// certificates for mTLS), proxying, HTTP/2 and connection pooling.
+//
+// Zero values keep the http.DefaultTransport behavior.
+type TransportConfig struct {
+	// CAFile is a PEM bundle of trusted roots. It replaces the system roots
+	// unless SystemCAs is set.
+	CAFile    string
+	SystemCAs bool
+	// CertFile and KeyFile hold the PEM client certificate and key for mTLS.
+	// The files are checked for changes at most every CertReloadInterval
+	// (default 1m; negative disables reloading) and rotated certificates are
+	// picked up for new connections without restarting.
+	CertFile           string
+	KeyFile            string
+	CertReloadInterval time.Duration
+	ServerName         string
+	MinTLSVersion      uint16
+	InsecureSkipVerify bool
+
+	// ProxyURL routes requests through a proxy, except for hosts matched by
+	// NoProxy (same syntax as the NO_PROXY variable). If ProxyURL is empty,
+	// HTTP_PROXY/HTTPS_PROXY/NO_PROXY from the environment are used, and
+	// NoProxy excludes hosts in addition to NO_PROXY.
+	ProxyURL string
+	NoProxy  string
+
+	// DisableHTTP2 forces HTTP/1.1.
+	DisableHTTP2 bool
+
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+}
+
+// NewTransport builds an *http.Transport from cfg.
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if cfg.DialTimeout > 0 {
+		dialer.Timeout = cfg.DialTimeout
+	}
+	if cfg.KeepAlive != 0 {
+		dialer.KeepAlive = cfg.KeepAlive
+	}
+	t.DialContext = dialer.DialContext
+
+	tlsCfg, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsCfg
+
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: parse proxy url: %w", err)
+		}
+		t.Proxy = excludeNoProxy(cfg.NoProxy, http.ProxyURL(proxy))
+	} else if cfg.NoProxy != "" {
+		t.Proxy = excludeNoProxy(cfg.NoProxy, http.ProxyFromEnvironment)
+	}
+
+	if cfg.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		// A non-nil empty map disables the bundled HTTP/2 support.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if cfg.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	return t, nil
+}
+
+func (cfg TransportConfig) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName:         cfg.ServerName,
+		MinVersion:         cfg.MinTLSVersion,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if c.MinVersion == 0 {
+		c.MinVersion = tls.VersionTLS12
+	}
+
+	if cfg.CAFile != "" {
+		pool := x509.NewCertPool()
+		if cfg.SystemCAs {
+			sys, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, fmt.Errorf("httpclient: load system roots: %w", err)
+			}
+			pool = sys
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: read ca file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("httpclient: no certificates found in %s", cfg.CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("httpclient: CertFile and KeyFile must be set together")
+		}
+		interval := cfg.CertReloadInterval
+		if interval == 0 {
+			interval = time.Minute
+		}
+		r, err := newCertReloader(cfg.CertFile, cfg.KeyFile, interval)
+		if err != nil {
+			return nil, err
+		}
+		c.GetClientCertificate = r.GetClientCertificate
+	}
+	return c, nil
+}
+
+// certReloader serves a client certificate and reloads it when the files
+// change on disk.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	mod, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("httpclient: load client certificate: %w", err)
+	}
+	r.cert, r.modTime, r.lastCheck = &cert, mod, time.Now()
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		st, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("httpclient: stat client certificate: %w", err)
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. If a
+// reload fails (e.g. the files are mid-rotation), the previous certificate is
+// kept and the reload is retried at the next check.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.interval > 0 && time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		if mod, err := r.latestModTime(); err == nil && !mod.Equal(r.modTime) {
+			prev := r.cert
+			if err := r.load(); err != nil {
+				r.cert = prev
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// excludeNoProxy wraps a Transport.Proxy function so hosts matched by list
+// connect directly.
+func excludeNoProxy(list string, proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if matchNoProxy(list, req.URL) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// matchNoProxy reports whether u's host is excluded from proxying by list, a
+// comma-separated NO_PROXY value: "*", host names (matching subdomains too,
+// with or without a leading dot), IP addresses, CIDR ranges, and any of those
+// with a ":port" suffix.
+func matchNoProxy(list string, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		name, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			name, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if eip := net.ParseIP(name); eip != nil {
+			if ip != nil && eip.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		name = strings.TrimPrefix(name, ".")
+		if host == name || strings.HasSuffix(host, "."+name) {
+			return true
+		}
+	}
+	return false
+}
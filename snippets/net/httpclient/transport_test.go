@@ -0,0 +1,164 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate with the given
+// common name and returns it.
+func writeClientCert(t *testing.T, certFile, keyFile, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func TestTransportConfig_MTLSWithReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	first := writeClientCert(t, certFile, keyFile, "client-v1")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(first)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600)
+
+	tr, err := NewTransport(TransportConfig{
+		CAFile:              caFile,
+		CertFile:            certFile,
+		KeyFile:             keyFile,
+		CertReloadInterval:  time.Nanosecond,
+		MaxIdleConnsPerHost: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(Options{Transport: tr})
+	get := func() string {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	if got := get(); got != "client-v1" {
+		t.Fatalf("got %q", got)
+	}
+
+	// Rotate the certificate on disk; new connections must present it.
+	time.Sleep(10 * time.Millisecond) // ensure a different mtime
+	second := writeClientCert(t, certFile, keyFile, "client-v2")
+	clientCAs.AddCert(second)
+	tr.CloseIdleConnections()
+	if got := get(); got != "client-v2" {
+		t.Fatalf("after rotation got %q", got)
+	}
+}
+
+func TestTransportConfig_ProxyAndNoProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	c := New(Options{TransportConfig: &TransportConfig{ProxyURL: proxy.URL, NoProxy: "localhost,.internal"}})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://api.example.invalid/x", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "proxied api.example.invalid" {
+		t.Fatalf("got %q", b)
+	}
+
+	// Without ProxyURL, NoProxy still applies on top of the environment.
+	tr, err := NewTransport(TransportConfig{NoProxy: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := tr.Proxy(req); u != nil || err != nil {
+		t.Fatalf("env proxy with NoProxy: %v, %v", u, err)
+	}
+	envProxy, _ := url.Parse("http://env-proxy:3128")
+	fromEnv := excludeNoProxy(".internal", http.ProxyURL(envProxy))
+	for host, want := range map[string]*url.URL{"svc.internal": nil, "api.example.com": envProxy} {
+		r, _ := http.NewRequest(http.MethodGet, "http://"+host, nil)
+		if got, _ := fromEnv(r); got != want {
+			t.Errorf("%s: proxy %v, want %v", host, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		list, u string
+		want    bool
+	}{
+		{"*", "http://a.example.com", true},
+		{".internal", "http://svc.internal:8080", true},
+		{"example.com", "https://api.example.com", true},
+		{"example.com", "https://notexample.com", false},
+		{"10.0.0.0/8", "http://10.1.2.3", true},
+		{"localhost:8080", "http://localhost:9090", false},
+		{"localhost:443", "https://localhost", true},
+	} {
+		u, _ := url.Parse(tc.u)
+		if got := matchNoProxy(tc.list, u); got != tc.want {
+			t.Errorf("matchNoProxy(%q, %q) = %v, want %v", tc.list, tc.u, got, tc.want)
+		}
+	}
+}
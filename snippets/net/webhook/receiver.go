@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissingHeaders   = errors.New("webhook: missing signature headers")
+	ErrInvalidTimestamp = errors.New("webhook: timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("webhook: no matching signature")
+	ErrDuplicateMessage = errors.New("webhook: duplicate message id")
+	ErrPayloadTooLarge  = errors.New("webhook: payload too large")
+)
+
+const (
+	defaultTolerance    = 5 * time.Minute
+	defaultMaxBodyBytes = 1 << 20
+	purgeInterval       = time.Minute
+)
+
+// IDStore remembers message IDs that have already been accepted.
+type IDStore interface {
+	// Add records id until expires. It returns false if id was already present.
+	Add(id string, expires time.Time) bool
+	// Remove forgets id so a redelivery of the message is accepted.
+	Remove(id string)
+}
+
+// VerifierOptions configures a Verifier.
+type VerifierOptions struct {
+	// Secrets are the accepted signing secrets. Several secrets may be given
+	// while rotating. At least one is required.
+	Secrets []string
+	// Tolerance is the maximum allowed clock difference between the message
+	// timestamp and now. Defaults to 5 minutes.
+	Tolerance time.Duration
+	// IDs deduplicates message IDs. Defaults to an in-memory store.
+	IDs IDStore
+	// MaxBodyBytes limits the request body read by Middleware. Defaults to 1 MiB.
+	MaxBodyBytes int64
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verifier checks Standard Webhooks signatures on incoming requests.
+type Verifier struct {
+	opt     VerifierOptions
+	secrets [][]byte
+}
+
+// NewVerifier creates a Verifier.
+func NewVerifier(opt VerifierOptions) (*Verifier, error) {
+	if len(opt.Secrets) == 0 {
+		return nil, errors.New("webhook: no secrets configured")
+	}
+	v := &Verifier{}
+	for _, s := range opt.Secrets {
+		b, err := ParseSecret(s)
+		if err != nil {
+			return nil, err
+		}
+		v.secrets = append(v.secrets, b)
+	}
+	if opt.Tolerance <= 0 {
+		opt.Tolerance = defaultTolerance
+	}
+	if opt.MaxBodyBytes <= 0 {
+		opt.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	if opt.IDs == nil {
+		opt.IDs = &MemoryIDStore{ids: make(map[string]time.Time), now: opt.Now}
+	}
+	v.opt = opt
+	return v, nil
+}
+
+// Verify checks the signature headers against body.
+//
+// It rejects messages whose timestamp is outside the tolerance window and, once
+// the signature is valid, message IDs that were seen before. Signatures are
+// compared in constant time.
+func (v *Verifier) Verify(h http.Header, body []byte) error {
+	id := h.Get(HeaderID)
+	tsRaw := h.Get(HeaderTimestamp)
+	sigs := h.Get(HeaderSignature)
+	if id == "" || tsRaw == "" || sigs == "" {
+		return ErrMissingHeaders
+	}
+	ts, err := strconv.ParseInt(tsRaw, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	now := v.opt.Now()
+	sent := time.Unix(ts, 0)
+	if sent.Before(now.Add(-v.opt.Tolerance)) || sent.After(now.Add(v.opt.Tolerance)) {
+		return ErrInvalidTimestamp
+	}
+
+	if !v.matches(id, ts, body, sigs) {
+		return ErrInvalidSignature
+	}
+	// Anything older than the tolerance window is rejected above, so IDs only
+	// need to be remembered until then.
+	if !v.opt.IDs.Add(id, sent.Add(v.opt.Tolerance)) {
+		return ErrDuplicateMessage
+	}
+	return nil
+}
+
+func (v *Verifier) matches(id string, ts int64, body []byte, header string) bool {
+	for _, secret := range v.secrets {
+		want := mac(secret, id, ts, body)
+		// The header holds space-separated "<version>,<base64>" entries.
+		for _, entry := range strings.Fields(header) {
+			version, sig, ok := strings.Cut(entry, ",")
+			if !ok || version != "v1" {
+				continue
+			}
+			got, err := base64.StdEncoding.DecodeString(sig)
+			if err != nil {
+				continue
+			}
+			if hmac.Equal(got, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware verifies each request before passing it to next. The body is
+// buffered (up to MaxBodyBytes) and restored so next can read it again.
+//
+// Rejections use 413 for oversized bodies, 409 for duplicates and 401 for
+// everything else. If next responds with a non-2xx status, the message ID is
+// forgotten so the sender's retry is not rejected as a duplicate.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, v.opt.MaxBodyBytes+1))
+		if err != nil {
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > v.opt.MaxBodyBytes {
+			http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := v.Verify(r.Header, body); err != nil {
+			code := http.StatusUnauthorized
+			if errors.Is(err, ErrDuplicateMessage) {
+				code = http.StatusConflict
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.code < 200 || sw.code > 299 {
+			v.opt.IDs.Remove(r.Header.Get(HeaderID))
+		}
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	wrote bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MemoryIDStore is an in-process IDStore. Expired IDs are purged lazily.
+type MemoryIDStore struct {
+	mu        sync.Mutex
+	ids       map[string]time.Time
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIDStore returns an empty MemoryIDStore.
+func NewMemoryIDStore() *MemoryIDStore {
+	return &MemoryIDStore{ids: make(map[string]time.Time), now: time.Now}
+}
+
+// Add implements IDStore.
+func (s *MemoryIDStore) Add(id string, expires time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastPurge) > purgeInterval {
+		for k, exp := range s.ids {
+			if now.After(exp) {
+				delete(s.ids, k)
+			}
+		}
+		s.lastPurge = now
+	}
+	if exp, ok := s.ids[id]; ok && !now.After(exp) {
+		return false
+	}
+	s.ids[id] = expires
+	return true
+}
+
+// Remove implements IDStore.
+func (s *MemoryIDStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, id)
+}
@@ -0,0 +1,294 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/io/atomicfile"
+	"github.com/shijianliangs/golang-snippets/snippets/net/httpclient"
+)
+
+// Header names defined by the Standard Webhooks specification.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+const secretPrefix = "whsec_"
+
+// ErrDelivery is returned by Sender.Send when a message could not be delivered
+// after all retries.
+var ErrDelivery = errors.New("webhook: delivery failed")
+
+// ParseSecret decodes a signing secret. Secrets in the Standard Webhooks form
+// ("whsec_" followed by base64) are decoded; any other string is used as raw
+// bytes.
+func ParseSecret(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("webhook: empty secret")
+	}
+	if !strings.HasPrefix(s, secretPrefix) {
+		return []byte(s), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, secretPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("webhook: decode secret: %w", err)
+	}
+	return b, nil
+}
+
+// Sign returns the versioned signature ("v1,<base64>") for a message.
+//
+// The signed content is "<id>.<unix timestamp>.<payload>" and the MAC is
+// HMAC-SHA256 keyed with secret.
+func Sign(secret []byte, id string, ts time.Time, payload []byte) string {
+	return "v1," + base64.StdEncoding.EncodeToString(mac(secret, id, ts.Unix(), payload))
+}
+
+func mac(secret []byte, id string, ts int64, payload []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(id))
+	m.Write([]byte{'.'})
+	m.Write([]byte(strconv.FormatInt(ts, 10)))
+	m.Write([]byte{'.'})
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// NewMessageID returns a random message ID of the form "msg_<hex>".
+func NewMessageID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "msg_" + hex.EncodeToString(b[:])
+}
+
+// DeadLetter stores messages that could not be delivered.
+type DeadLetter interface {
+	Put(ctx context.Context, e DeadLetterEntry) error
+}
+
+// DeadLetterEntry describes one failed delivery.
+type DeadLetterEntry struct {
+	URL       string          `json:"url"`
+	ID        string          `json:"id"`
+	Timestamp int64           `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+	Error     string          `json:"error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// FileDeadLetter appends failed deliveries to a JSON-lines file.
+//
+// Each Put is flushed to disk before returning, so entries survive restarts and
+// can be re-sent later with Entries + Sender.Redeliver, then dropped with
+// Remove once delivered.
+type FileDeadLetter struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Put appends e to the file.
+func (d *FileDeadLetter) Put(_ context.Context, e DeadLetterEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("webhook: encode dead letter: %w", err)
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("webhook: open dead letter: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("webhook: write dead letter: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("webhook: sync dead letter: %w", err)
+	}
+	return f.Close()
+}
+
+// Entries reads all entries from the file. A missing file yields no entries.
+func (d *FileDeadLetter) Entries() ([]DeadLetterEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.entries()
+}
+
+// Remove rewrites the file atomically without the entries with the given
+// message IDs. Entries Put since they were read are kept.
+func (d *FileDeadLetter) Remove(ids ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entries, err := d.entries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	err = atomicfile.WriteFileFunc(d.Path, 0o600, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			if slices.Contains(ids, e.ID) {
+				continue
+			}
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("webhook: rewrite dead letter: %w", err)
+	}
+	return nil
+}
+
+func (d *FileDeadLetter) entries() ([]DeadLetterEntry, error) {
+	b, err := os.ReadFile(d.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("webhook: read dead letter: %w", err)
+	}
+	var out []DeadLetterEntry
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var e DeadLetterEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("webhook: parse dead letter: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// SenderOptions configures a Sender.
+type SenderOptions struct {
+	// Secret is the signing secret, usually "whsec_<base64>". Required.
+	Secret string
+	// Client performs the HTTP calls and owns the retry/backoff policy.
+	// If nil, httpclient.New(httpclient.Options{MaxRetries: 5}) is used.
+	Client *httpclient.Client
+	// DeadLetter receives messages that failed permanently. Optional.
+	DeadLetter DeadLetter
+	// Header is added to every outgoing request (e.g. User-Agent).
+	Header http.Header
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Sender delivers signed webhook messages.
+type Sender struct {
+	opt    SenderOptions
+	secret []byte
+}
+
+// NewSender creates a Sender. It fails if the secret is empty or malformed.
+func NewSender(opt SenderOptions) (*Sender, error) {
+	secret, err := ParseSecret(opt.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Client == nil {
+		opt.Client = httpclient.New(httpclient.Options{MaxRetries: 5})
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	return &Sender{opt: opt, secret: secret}, nil
+}
+
+// Send POSTs payload (JSON) to url with a fresh message ID and returns that ID.
+//
+// Transient failures are retried by the underlying httpclient.Client. If the
+// message still cannot be delivered (transport error or non-2xx status), it is
+// written to the dead letter and an error wrapping ErrDelivery is returned.
+func (s *Sender) Send(ctx context.Context, url string, payload []byte) (string, error) {
+	id := NewMessageID()
+	return id, s.send(ctx, url, id, payload)
+}
+
+// Redeliver re-sends a dead-lettered entry, keeping its original message ID so
+// receivers can deduplicate it. A failure is returned without dead-lettering
+// the entry again; it stays where it is until the caller removes it (see
+// FileDeadLetter.Remove).
+func (s *Sender) Redeliver(ctx context.Context, e DeadLetterEntry) error {
+	if err := s.post(ctx, e.URL, e.ID, s.opt.Now(), e.Payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrDelivery, err)
+	}
+	return nil
+}
+
+func (s *Sender) send(ctx context.Context, url, id string, payload []byte) error {
+	ts := s.opt.Now()
+	err := s.post(ctx, url, id, ts, payload)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%w: %v", ErrDelivery, err)
+	if s.opt.DeadLetter != nil {
+		e := DeadLetterEntry{
+			URL:       url,
+			ID:        id,
+			Timestamp: ts.Unix(),
+			Payload:   payload,
+			Error:     err.Error(),
+			FailedAt:  s.opt.Now(),
+		}
+		if dlErr := s.opt.DeadLetter.Put(ctx, e); dlErr != nil {
+			return errors.Join(err, dlErr)
+		}
+	}
+	return err
+}
+
+func (s *Sender) post(ctx context.Context, url, id string, ts time.Time, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for k, vs := range s.opt.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(s.secret, id, ts, payload))
+
+	resp, err := s.opt.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shijianliangs/golang-snippets/snippets/net/httpclient"
+)
+
+var testSecret = "whsec_" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestSendAndVerify(t *testing.T) {
+	v, err := NewVerifier(VerifierOptions{Secrets: []string{testSecret}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int32
+	var got string
+	srv := httptest.NewServer(v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to exercise retries with a rewound body.
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	})))
+	defer srv.Close()
+
+	s, err := NewSender(SenderOptions{
+		Secret: testSecret,
+		Client: httpclient.New(httpclient.Options{MaxRetries: 3, BaseBackoff: time.Millisecond}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Send(context.Background(), srv.URL, []byte(`{"event":"ping"}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got != `{"event":"ping"}` {
+		t.Fatalf("handler got %q", got)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v, err := NewVerifier(VerifierOptions{Secrets: []string{testSecret}, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := ParseSecret(testSecret)
+	body := []byte(`{}`)
+	headers := func(id string, ts time.Time, sig string) http.Header {
+		h := http.Header{}
+		h.Set(HeaderID, id)
+		h.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+		h.Set(HeaderSignature, sig)
+		return h
+	}
+
+	if err := v.Verify(headers("msg_1", now, Sign(secret, "msg_1", now, body)), body); err != nil {
+		t.Fatalf("valid message: %v", err)
+	}
+	if err := v.Verify(headers("msg_1", now, Sign(secret, "msg_1", now, body)), body); !errors.Is(err, ErrDuplicateMessage) {
+		t.Fatalf("duplicate: got %v", err)
+	}
+	old := now.Add(-10 * time.Minute)
+	if err := v.Verify(headers("msg_2", old, Sign(secret, "msg_2", old, body)), body); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Fatalf("stale: got %v", err)
+	}
+	if err := v.Verify(headers("msg_3", now, Sign([]byte("other"), "msg_3", now, body)), body); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("wrong secret: got %v", err)
+	}
+	// Several signatures may be listed; any match is accepted.
+	multi := Sign([]byte("old"), "msg_4", now, body) + " " + Sign(secret, "msg_4", now, body)
+	if err := v.Verify(headers("msg_4", now, multi), body); err != nil {
+		t.Fatalf("multiple signatures: %v", err)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() || r.Header.Get(HeaderID) == "stuck" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	dl := &FileDeadLetter{Path: filepath.Join(t.TempDir(), "dead.jsonl")}
+	s, err := NewSender(SenderOptions{Secret: testSecret, DeadLetter: dl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.Send(context.Background(), srv.URL, []byte(`{"n":1}`))
+	if !errors.Is(err, ErrDelivery) {
+		t.Fatalf("expected ErrDelivery, got %v", err)
+	}
+	entries, err := dl.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != id || string(entries[0].Payload) != `{"n":1}` {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	// A failed redelivery is not dead-lettered a second time.
+	if err := s.Redeliver(context.Background(), entries[0]); !errors.Is(err, ErrDelivery) {
+		t.Fatalf("redeliver: %v", err)
+	}
+	if entries, _ = dl.Entries(); len(entries) != 1 {
+		t.Fatalf("after failed redelivery: %d entries", len(entries))
+	}
+
+	// Redeliver, then drop what went through.
+	dl.Put(context.Background(), DeadLetterEntry{URL: srv.URL, ID: "stuck", Payload: []byte(`{}`)})
+	healthy.Store(true)
+	entries, _ = dl.Entries()
+	var delivered []string
+	for _, e := range entries {
+		if s.Redeliver(context.Background(), e) == nil {
+			delivered = append(delivered, e.ID)
+		}
+	}
+	if err := dl.Remove(delivered...); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = dl.Entries(); err != nil || len(entries) != 1 || entries[0].ID != "stuck" {
+		t.Fatalf("after remove: %+v, %v", entries, err)
+	}
+}